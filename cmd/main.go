@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	flags "github.com/jessevdk/go-flags"
+	"github.com/telia-oss/gcp-env/pkg/credentials"
 	environment "github.com/telia-oss/gcp-env/pkg/environment"
-	"github.com/telia-oss/gcp-env/pkg/utils"
-	"golang.org/x/oauth2"
+	"github.com/telia-oss/gcp-env/pkg/secrets"
 	"golang.org/x/oauth2/google"
 )
 
@@ -19,16 +24,69 @@ var command rootCommand
 var version string
 
 type rootCommand struct {
-	Version func()      `short:"v" long:"version" description:"Print the version and exit."`
-	Exec    execCommand `command:"exec" description:"Execute a command."`
+	Version  func()          `short:"v" long:"version" description:"Print the version and exit."`
+	Exec     execCommand     `command:"exec" description:"Execute a command."`
+	Export   exportCommand   `command:"export" description:"Print the resolved secrets."`
+	Validate validateCommand `command:"validate" description:"Check that every secret reference resolves, without printing secrets."`
+	Encrypt  encryptCommand  `command:"encrypt" description:"Encrypt a secret and print its kms:// reference."`
 }
 
-const (
-	cloudkmsScope      = "https://www.googleapis.com/auth/cloudkms"
-	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
-)
+// clientFlags configure the Google Cloud clients of the commands resolving secrets.
+type clientFlags struct {
+	ImpersonateServiceAccount string        `long:"impersonate-service-account" description:"Access secrets as this service account, impersonated with the credentials of gcp-env."`
+	ImpersonateDelegates      []string      `long:"impersonate-delegate" description:"Service account in the delegation chain of --impersonate-service-account. Can be repeated."`
+	ImpersonateLifetime       time.Duration `long:"impersonate-lifetime" default:"1h" description:"Lifetime of the impersonated tokens."`
+	SMEndpoint                string        `long:"sm-endpoint" description:"Secret Manager endpoint (host:port), e.g. an emulator or a Private Service Connect endpoint. Overrides GCP_ENV_SM_ENDPOINT."`
+	KMSEndpoint               string        `long:"kms-endpoint" description:"KMS endpoint (host:port). Overrides GCP_ENV_KMS_ENDPOINT."`
+	Insecure                  bool          `long:"insecure" description:"Connect without authentication and TLS, for local emulators only."`
+}
+
+// options returns the client options selected by the flags.
+func (f clientFlags) options() []secrets.ClientOption {
+	var opts []secrets.ClientOption
+	if f.ImpersonateServiceAccount != "" {
+		opts = append(opts, secrets.WithImpersonation(credentials.ImpersonateOptions{
+			TargetServiceAccount: f.ImpersonateServiceAccount,
+			Delegates:            f.ImpersonateDelegates,
+			Lifetime:             f.ImpersonateLifetime,
+		}))
+	}
+	if f.SMEndpoint != "" {
+		opts = append(opts, secrets.WithSecretManagerEndpoint(f.SMEndpoint))
+	}
+	if f.KMSEndpoint != "" {
+		opts = append(opts, secrets.WithKMSEndpoint(f.KMSEndpoint))
+	}
+	if f.Insecure {
+		opts = append(opts, secrets.WithInsecure())
+	}
+	return opts
+}
+
+// provider creates the secrets provider configured by the flags. No
+// credentials are looked up for insecure clients.
+func (f clientFlags) provider(ctx context.Context) (*secrets.Provider, error) {
+	var creds *google.Credentials
+	if !f.Insecure {
+		var err error
+		if creds, err = credentials.FromEnvironment(ctx); err != nil {
+			return nil, fmt.Errorf("failed to initialize credentials for Google Cloud SDK in gcp-env: %w", err)
+		}
+	}
+	provider, err := secrets.NewClient(ctx, creds, f.options()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize gcp-env: %w", err)
+	}
+	return provider, nil
+}
 
-type execCommand struct{}
+type execCommand struct {
+	clientFlags
+	Concurrency int           `long:"concurrency" default:"10" description:"Maximum number of secrets resolved in parallel."`
+	DryRun      bool          `long:"dry-run" description:"Validate the secret references instead of executing the command."`
+	Timeout     time.Duration `long:"timeout" description:"Deadline for resolving all secrets, including retries (e.g. 30s). No deadline by default."`
+	MaxAttempts int           `long:"max-attempts" default:"5" description:"Maximum number of attempts per API call, retrying transient failures with exponential backoff."`
+}
 
 // Execute the exec subcommand.
 func (c *execCommand) Execute(args []string) error {
@@ -37,46 +95,191 @@ func (c *execCommand) Execute(args []string) error {
 	}
 	var err error
 
+	ctx := context.Background()
+	cancel := func() {}
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	defer cancel()
+	provider, err := c.provider(ctx)
+	if err != nil {
+		return err
+	}
+	env := environment.NewWithProvider(provider)
+	env.SecretProvider.Concurrency = c.Concurrency
+	retry := secrets.DefaultRetryPolicy
+	retry.MaxAttempts = c.MaxAttempts
+	env.SecretProvider.Retry = &retry
+	child := environment.Env(environ())
+	if c.DryRun {
+		return validate(ctx, env, child, "table")
+	}
+	// the command is only needed when it is executed, so that --dry-run can
+	// check the references where it is not installed
 	path, err := exec.LookPath(args[0])
 	if err != nil {
-		return fmt.Errorf("failed to validate command: %s", err)
+		return fmt.Errorf("failed to validate command: %w", err)
+	}
+	env.Source, env.Sink = child, child
+	if err := env.PopulateContext(ctx); err != nil {
+		return fmt.Errorf("failed to populate environment: %w", err)
+	}
+	cancel()
+
+	if err := syscall.Exec(path, args, child.List()); err != nil {
+		return fmt.Errorf("failed to execute command: %w", err)
+	}
+	return nil
+}
+
+type exportCommand struct {
+	clientFlags
+	Format      string `short:"f" long:"format" default:"shell" choice:"shell" choice:"dotenv" choice:"json" choice:"yaml" choice:"systemd" description:"Output format."`
+	File        string `long:"file" description:"Resolve the variables of a dotenv file instead of the environment."`
+	Concurrency int    `long:"concurrency" default:"10" description:"Maximum number of secrets resolved in parallel."`
+}
+
+// Execute the export subcommand.
+func (c *exportCommand) Execute(args []string) error {
+	vars := environ()
+	if c.File != "" {
+		var err error
+		if vars, err = readDotenv(c.File); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	provider, err := c.provider(ctx)
+	if err != nil {
+		return err
+	}
+	env := environment.NewWithProvider(provider)
+	env.SecretProvider.Concurrency = c.Concurrency
+	resolved, err := env.Resolve(ctx, vars)
+	if err != nil {
+		return fmt.Errorf("failed to resolve environment: %w", err)
+	}
+	return environment.Export(os.Stdout, environment.Format(c.Format), resolved)
+}
+
+type validateCommand struct {
+	clientFlags
+	Format string `short:"f" long:"format" default:"table" choice:"table" choice:"json" description:"Report format."`
+	File   string `long:"file" description:"Validate the variables of a dotenv file instead of the environment."`
+}
+
+// Execute the validate subcommand.
+func (c *validateCommand) Execute(args []string) error {
+	vars := environ()
+	if c.File != "" {
+		var err error
+		if vars, err = readDotenv(c.File); err != nil {
+			return err
+		}
 	}
 
-	oAuthCredentials := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
 	ctx := context.Background()
+	provider, err := c.provider(ctx)
+	if err != nil {
+		return err
+	}
+	env := environment.NewWithProvider(provider)
+	return validate(ctx, env, vars, c.Format)
+}
 
-	var creds *google.Credentials
-	if len(oAuthCredentials) > 0 {
-		var contents string
-		contents, _, err = utils.PathOrContents(oAuthCredentials)
-		if err != nil {
-			panic(fmt.Errorf("failed to initialize credentials for Google Cloud SDK in gcp-env: %s from GOOGLE_OAUTH_ACCESS_TOKEN", err))
+// validate prints a report of the references in vars, and fails if any of
+// them does not resolve.
+func validate(ctx context.Context, env *environment.Manager, vars map[string]string, format string) error {
+	results := env.Validate(ctx, vars)
+	failed := 0
+	for _, r := range results {
+		if !r.OK {
+			failed++
 		}
-		token := &oauth2.Token{AccessToken: contents}
-		creds = &google.Credentials{
-			TokenSource: utils.StaticTokenSource{oauth2.StaticTokenSource(token)},
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
 		}
-	} else {
-		creds, err = google.FindDefaultCredentials(ctx, cloudkmsScope, cloudPlatformScope)
-		if err != nil {
-			panic(fmt.Errorf("failed to initialize credentials for Google Cloud SDK in gcp-env: %s", err))
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSTATUS\tREFERENCE\tERROR")
+		for _, r := range results {
+			status := "ok"
+			if !r.OK {
+				status = "failed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, status, strings.Join(r.References, ","), r.Error)
+		}
+		if err := w.Flush(); err != nil {
+			return err
 		}
 	}
-	env, err := environment.New(ctx, creds)
 
-	if err != nil {
-		return fmt.Errorf("failed to initialize gcp-env: %s", err)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d variables failed validation", failed, len(results))
+	}
+	return nil
+}
+
+type encryptCommand struct {
+	clientFlags
+	Key  string `long:"key" required:"true" description:"KMS key: projects/{PROJECT}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}."`
+	File string `long:"file" description:"Read the secret from a file instead of stdin."`
+}
+
+// Execute the encrypt subcommand.
+func (c *encryptCommand) Execute(args []string) error {
+	var (
+		plaintext []byte
+		err       error
+	)
+	if c.File != "" {
+		plaintext, err = ioutil.ReadFile(c.File)
+	} else {
+		plaintext, err = ioutil.ReadAll(os.Stdin)
 	}
-	if err := env.Populate(); err != nil {
-		return fmt.Errorf("failed to populate environment: %s", err)
+	if err != nil {
+		return fmt.Errorf("failed to read secret: %w", err)
 	}
 
-	if err := syscall.Exec(path, args, os.Environ()); err != nil {
-		return fmt.Errorf("failed to execute command: %s", err)
+	ctx := context.Background()
+	provider, err := c.provider(ctx)
+	if err != nil {
+		return err
+	}
+	ref, err := provider.EncryptContext(ctx, c.Key, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
 	}
+	fmt.Println(ref)
 	return nil
 }
 
+// environ returns the variables of the current environment.
+func environ() map[string]string {
+	vars, _ := environment.OSEnv{}.Environ()
+	return vars
+}
+
+func readDotenv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables: %w", err)
+	}
+	defer f.Close()
+	vars, err := environment.ParseDotenv(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables: '%s': %w", path, err)
+	}
+	return vars, nil
+}
+
 func init() {
 	command.Version = func() {
 		fmt.Println(version)
@@ -0,0 +1,92 @@
+//go:build e2e
+// +build e2e
+
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/telia-oss/gcp-env/pkg/gcpenvtest"
+)
+
+const key = "projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/my-key"
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcp-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, "gcp-env")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	server, err := gcpenvtest.NewServer(&gcpenvtest.Fixture{
+		Project: "my-project",
+		Secrets: map[string]gcpenvtest.Versions{
+			"db-password": {{Value: "old"}, {Value: "new", Disabled: true}},
+			"api-key":     {{Value: "s3cr3t"}},
+		},
+		Keys: []string{key},
+	})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	defer server.Close()
+	ciphertext, err := server.Encrypt(key, []byte("encrypted"))
+	if err != nil {
+		t.Fatalf("Server.Encrypt() error = %v", err)
+	}
+	env := append(server.Env(), "PATH="+os.Getenv("PATH"))
+
+	tests := []struct {
+		name    string
+		vars    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "resolves secrets",
+			vars: []string{
+				"DB_PASSWORD=sm://db-password#1",
+				"API_KEY=sm://projects/my-project/secrets/api-key",
+				"TOKEN=kms://" + key + "?ciphertext=" + base64.StdEncoding.EncodeToString(ciphertext),
+			},
+			want: "old\ns3cr3t\nencrypted\n",
+		},
+		{
+			name:    "fails on disabled versions",
+			vars:    []string{"DB_PASSWORD=sm://db-password", "API_KEY=sm://api-key", "TOKEN=plain"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(bin, "exec", "--insecure", "printenv", "DB_PASSWORD", "API_KEY", "TOKEN")
+			cmd.Env = append(env, tt.vars...)
+			out, err := cmd.Output()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gcp-env exec: error = %v, wantErr %v\n%s", err, tt.wantErr, out)
+			}
+			if got := string(out); !tt.wantErr && got != tt.want {
+				t.Errorf("gcp-env exec: output = %q, want %q", got, tt.want)
+			}
+			if exitErr, ok := err.(*exec.ExitError); ok && strings.Contains(string(exitErr.Stderr), "s3cr3t") {
+				t.Errorf("gcp-env exec: error leaks a secret: %s", exitErr.Stderr)
+			}
+		})
+	}
+	// --dry-run checks the references without the command being installed
+	cmd := exec.Command(bin, "exec", "--insecure", "--dry-run", "--", "not-installed-app")
+	cmd.Env = append(env, "API_KEY=sm://api-key")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("gcp-env exec --dry-run: error = %v\n%s", err, out)
+	}
+}
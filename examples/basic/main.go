@@ -6,50 +6,17 @@ import (
 	"os"
 	"strings"
 
+	"github.com/telia-oss/gcp-env/pkg/credentials"
 	environment "github.com/telia-oss/gcp-env/pkg/environment"
-	utils "github.com/telia-oss/gcp-env/pkg/utils"
-
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-)
-
-const (
-	cloudkmsScope      = "https://www.googleapis.com/auth/cloudkms"
-	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
 )
 
 func main() {
 	// Populate secrets using gcp-env
 
 	ctx := context.Background()
-
-	oAuthCredentials := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
-
-	var creds *google.Credentials
-	var err error
-	if len(oAuthCredentials) > 0 {
-		var contents string
-		contents, _, err = utils.PathOrContents(oAuthCredentials)
-		if err != nil {
-			panic(fmt.Errorf("failed to initialize credentials for Google Cloud SDK in gcp-env: %s from GOOGLE_OAUTH_ACCESS_TOKEN", err))
-		}
-		token := &oauth2.Token{AccessToken: contents}
-		creds = &google.Credentials{
-			TokenSource: utils.StaticTokenSource{oauth2.StaticTokenSource(token)},
-		}
-	} else {
-		creds, err = google.FindDefaultCredentials(ctx, cloudkmsScope, cloudPlatformScope)
-		if err != nil {
-			panic(fmt.Errorf("failed to initialize credentials for Google Cloud SDK in gcp-env: %s", err))
-		}
-	}
-
-	env, err := environment.New(ctx, creds)
-	if err != nil {
-		panic(fmt.Errorf("failed to initialize gcp-env: %s", err))
-	}
-	if err := env.Populate(); err != nil {
-		panic(fmt.Errorf("failed to populate environment: %s", err))
+	if err := populate(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	fmt.Printf("List of environment variables\n")
@@ -62,6 +29,24 @@ func main() {
 	fmt.Printf("%v", envs)
 }
 
+func populate(ctx context.Context) error {
+	// GOOGLE_OAUTH_ACCESS_TOKEN (a token or a path to one) or the application
+	// default credentials, passing nil to environment.New does the same
+	creds, err := credentials.FromEnvironment(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize credentials for Google Cloud SDK in gcp-env: %w", err)
+	}
+
+	env, err := environment.New(ctx, creds)
+	if err != nil {
+		return fmt.Errorf("failed to initialize gcp-env: %w", err)
+	}
+	if err := env.PopulateContext(ctx); err != nil {
+		return fmt.Errorf("failed to populate environment: %w", err)
+	}
+	return nil
+}
+
 func parseEnvironmentVariable(s string) (string, string) {
 	pair := strings.SplitN(s, "=", 2)
 	return pair[0], pair[1]
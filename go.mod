@@ -11,6 +11,9 @@ require (
 	golang.org/x/oauth2 v0.0.0-20210201163806-010130855d6c
 	google.golang.org/api v0.39.0
 	google.golang.org/genproto v0.0.0-20210207032614-bba0dbe2a9ea
+	google.golang.org/grpc v1.35.0
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.3.0
 )
 
 go 1.15
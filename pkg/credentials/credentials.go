@@ -0,0 +1,103 @@
+// Package credentials finds the Google Cloud credentials used by gcp-env.
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/telia-oss/gcp-env/pkg/utils"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// TokenEnv holds an OAuth2 access token, or the path to a file holding one.
+	TokenEnv = "GOOGLE_OAUTH_ACCESS_TOKEN"
+
+	cloudkmsScope      = "https://www.googleapis.com/auth/cloudkms"
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// DefaultScopes are requested when Options.Scopes is empty.
+var DefaultScopes = []string{cloudkmsScope, cloudPlatformScope}
+
+// Options select the credentials returned by Find. The first one set is used.
+type Options struct {
+	// Token is an OAuth2 access token.
+	Token string
+	// TokenFile is the path to a file holding an OAuth2 access token, which is
+	// read again when it changes (see FileTokenSource).
+	TokenFile string
+	// KeyFile is the path to a JSON key file, e.g. of a service account.
+	KeyFile string
+	// Scopes requested for key files and application default credentials.
+	// Defaults to DefaultScopes.
+	Scopes []string
+}
+
+// Find returns the credentials selected by opts, or the application default
+// credentials when none is set.
+func Find(ctx context.Context, opts Options) (*google.Credentials, error) {
+	scopes := opts.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	switch {
+	case opts.Token != "":
+		return staticToken(opts.Token), nil
+	case opts.TokenFile != "":
+		// read the token up front to fail early
+		ts := NewFileTokenSource(opts.TokenFile)
+		if _, err := ts.Token(); err != nil {
+			return nil, err
+		}
+		return &google.Credentials{TokenSource: ts}, nil
+	case opts.KeyFile != "":
+		key, err := ioutil.ReadFile(opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		creds, err := google.CredentialsFromJSON(ctx, key, scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key file: '%s': %w", opts.KeyFile, err)
+		}
+		return creds, nil
+	}
+	creds, err := google.FindDefaultCredentials(ctx, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find default credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// FromEnvironment returns the credentials selected by GOOGLE_OAUTH_ACCESS_TOKEN
+// (a token or the path to a file holding one, see FileTokenSource), or the
+// application default credentials when it is not set.
+func FromEnvironment(ctx context.Context) (*google.Credentials, error) {
+	var opts Options
+	if value := os.Getenv(TokenEnv); value != "" {
+		path, err := homedir.Expand(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token from %s: %w", TokenEnv, err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			opts.TokenFile = path
+		} else {
+			opts.Token = value
+		}
+	}
+	return Find(ctx, opts)
+}
+
+// staticToken returns credentials for a fixed access token.
+func staticToken(token string) *google.Credentials {
+	return &google.Credentials{
+		TokenSource: utils.StaticTokenSource{
+			TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: strings.TrimSpace(token)}),
+		},
+	}
+}
@@ -0,0 +1,126 @@
+package credentials_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/telia-oss/gcp-env/pkg/credentials"
+	"github.com/telia-oss/gcp-env/pkg/utils"
+)
+
+const keyFile = `{
+  "type": "authorized_user",
+  "client_id": "client-id",
+  "client_secret": "client-secret",
+  "refresh_token": "refresh-token",
+  "quota_project_id": "key-project"
+}`
+
+func writeFile(t *testing.T, dir, name, contents string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := writeFile(t, dir, "token", "file-token\n")
+	key := writeFile(t, dir, "key.json", keyFile)
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", key)
+
+	tests := []struct {
+		description string
+		opts        credentials.Options
+		wantToken   string
+		wantErr     bool
+	}{
+		{
+			description: "token",
+			opts:        credentials.Options{Token: "static-token", KeyFile: key},
+			wantToken:   "static-token",
+		},
+		{
+			description: "token file",
+			opts:        credentials.Options{TokenFile: tokenFile},
+			wantToken:   "file-token",
+		},
+		{
+			description: "missing token file",
+			opts:        credentials.Options{TokenFile: filepath.Join(dir, "missing")},
+			wantErr:     true,
+		},
+		{
+			description: "key file",
+			opts:        credentials.Options{KeyFile: key},
+		},
+		{
+			description: "invalid key file",
+			opts:        credentials.Options{KeyFile: tokenFile},
+			wantErr:     true,
+		},
+		{
+			description: "application default credentials",
+			opts:        credentials.Options{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			creds, err := credentials.Find(context.TODO(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantToken == "" {
+				if creds.JSON == nil {
+					t.Errorf("Find() = %+v, want credentials from the key file", creds)
+				}
+				return
+			}
+			switch creds.TokenSource.(type) {
+			case utils.StaticTokenSource, *credentials.FileTokenSource:
+			default:
+				t.Errorf("Find() token source = %T, want a static or file token source", creds.TokenSource)
+			}
+			token, err := creds.TokenSource.Token()
+			if err != nil || token.AccessToken != tt.wantToken {
+				t.Errorf("Find() token = %v, %v, want %v", token, err, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(credentials.TokenEnv)
+
+	for value, want := range map[string]string{
+		"env-token": "env-token",
+		writeFile(t, dir, "token", "file-token\n"): "file-token",
+	} {
+		os.Setenv(credentials.TokenEnv, value)
+		creds, err := credentials.FromEnvironment(context.TODO())
+		if err != nil {
+			t.Fatalf("FromEnvironment() error = %v", err)
+		}
+		if token, err := creds.TokenSource.Token(); err != nil || token.AccessToken != want {
+			t.Errorf("FromEnvironment() token = %v, %v, want %v", token, err, want)
+		}
+	}
+}
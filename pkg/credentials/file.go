@@ -0,0 +1,103 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultExpiryWindow is used when FileTokenSource.ExpiryWindow is not set.
+const DefaultExpiryWindow = time.Minute
+
+// FileTokenSource is an oauth2.TokenSource reading the access token from a
+// file, e.g. one rotated by a sidecar. The file is read again whenever it
+// changes, or when the token is about to expire. It holds either the token
+// itself or a JSON object with access_token and expiry (RFC 3339) fields.
+type FileTokenSource struct {
+	// Path of the token file.
+	Path string
+	// ExpiryWindow is how long before its expiry a token is read again.
+	// Defaults to DefaultExpiryWindow.
+	ExpiryWindow time.Duration
+
+	mu      sync.Mutex
+	token   *oauth2.Token
+	modTime time.Time
+	size    int64
+}
+
+// NewFileTokenSource returns a FileTokenSource for the file at path.
+func NewFileTokenSource(path string) *FileTokenSource {
+	return &FileTokenSource{Path: path}
+}
+
+// Token returns the token in the file, reading it again if needed.
+func (s *FileTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	changed := !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+	if s.token != nil && !changed && !s.expiring(s.token) {
+		return s.token, nil
+	}
+
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	token, err := parseToken(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: '%s': %w", s.Path, err)
+	}
+	if !token.Expiry.IsZero() && !token.Expiry.After(time.Now()) {
+		return nil, fmt.Errorf("failed to read token file: '%s': token expired at %s", s.Path, token.Expiry.Format(time.RFC3339))
+	}
+	s.token, s.modTime, s.size = token, info.ModTime(), info.Size()
+	return token, nil
+}
+
+// expiring reports whether the token expires within the expiry window.
+func (s *FileTokenSource) expiring(token *oauth2.Token) bool {
+	if token.Expiry.IsZero() {
+		return false
+	}
+	window := s.ExpiryWindow
+	if window == 0 {
+		window = DefaultExpiryWindow
+	}
+	return time.Now().Add(window).After(token.Expiry)
+}
+
+// parseToken parses the contents of a token file.
+func parseToken(data []byte) (*oauth2.Token, error) {
+	contents := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(contents, "{") {
+		if contents == "" {
+			return nil, fmt.Errorf("empty token")
+		}
+		return &oauth2.Token{AccessToken: contents}, nil
+	}
+	var token struct {
+		AccessToken string    `json:"access_token"`
+		TokenType   string    `json:"token_type"`
+		Expiry      time.Time `json:"expiry"`
+	}
+	// the error is left out, as it may quote the token
+	if err := json.Unmarshal([]byte(contents), &token); err != nil {
+		return nil, fmt.Errorf("invalid JSON token")
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("missing access_token")
+	}
+	return &oauth2.Token{AccessToken: token.AccessToken, TokenType: token.TokenType, Expiry: token.Expiry}, nil
+}
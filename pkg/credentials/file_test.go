@@ -0,0 +1,93 @@
+package credentials_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/telia-oss/gcp-env/pkg/credentials"
+)
+
+func TestFileTokenSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	// write replaces the token file, as a sidecar rotating the token would
+	modTime := time.Now().Add(-time.Hour)
+	write := func(contents string) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := credentials.NewFileTokenSource(path)
+	token := func() string {
+		t.Helper()
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatalf("FileTokenSource.Token() error = %v", err)
+		}
+		return token.AccessToken
+	}
+
+	write("token-1\n")
+	if got := token(); got != "token-1" {
+		t.Errorf("FileTokenSource.Token() = %v, want %v", got, "token-1")
+	}
+
+	// the file is only read again when it changes
+	if err := ioutil.WriteFile(path, []byte("token-x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := token(); got != "token-1" {
+		t.Errorf("FileTokenSource.Token() = %v, want the cached %v", got, "token-1")
+	}
+	write("token-2")
+	if got := token(); got != "token-2" {
+		t.Errorf("FileTokenSource.Token() = %v, want %v", got, "token-2")
+	}
+
+	// tokens about to expire are read again even if the file did not change
+	expiry := time.Now().Add(30 * time.Second).UTC().Format(time.RFC3339)
+	write(`{"access_token":"token-3","expiry":"` + expiry + `"}`)
+	if got := token(); got != "token-3" {
+		t.Errorf("FileTokenSource.Token() = %v, want %v", got, "token-3")
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"access_token":"token-4","expiry":"`+expiry+`"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := token(); got != "token-4" {
+		t.Errorf("FileTokenSource.Token() = %v, want %v", got, "token-4")
+	}
+
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	write(`{"access_token":"s3cr3t-token","expiry":"` + expired + `"}`)
+	if _, err := ts.Token(); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("FileTokenSource.Token() error = %v, want expired token", err)
+	}
+
+	write(`{"access_token":"s3cr3t-token"`)
+	if _, err := ts.Token(); err == nil || strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("FileTokenSource.Token() error = %v, want an error without the token", err)
+	}
+
+	os.Remove(path)
+	if _, err := ts.Token(); err == nil {
+		t.Errorf("FileTokenSource.Token() for a missing file, want error")
+	}
+}
@@ -0,0 +1,88 @@
+package credentials_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/telia-oss/gcp-env/pkg/credentials"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+func TestImpersonate(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got, want := r.URL.Path, "/v1/projects/-/serviceAccounts/target@p.iam.gserviceaccount.com:generateAccessToken"; got != want {
+			t.Errorf("path = %v, want %v", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer base-token"; got != want {
+			t.Errorf("Authorization = %v, want %v", got, want)
+		}
+		var req struct {
+			Delegates []string `json:"delegates"`
+			Lifetime  string   `json:"lifetime"`
+			Scope     []string `json:"scope"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		if want := []string{"projects/-/serviceAccounts/delegate@p.iam.gserviceaccount.com"}; !reflect.DeepEqual(req.Delegates, want) {
+			t.Errorf("delegates = %v, want %v", req.Delegates, want)
+		}
+		if want := "900s"; req.Lifetime != want {
+			t.Errorf("lifetime = %v, want %v", req.Lifetime, want)
+		}
+		if !reflect.DeepEqual(req.Scope, credentials.DefaultScopes) {
+			t.Errorf("scope = %v, want %v", req.Scope, credentials.DefaultScopes)
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken": "impersonated-token",
+			"expireTime":  expiry.Format(time.RFC3339),
+		})
+	}))
+	defer server.Close()
+
+	base := &google.Credentials{
+		ProjectID:   "base-project",
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base-token"}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	creds, err := credentials.Impersonate(ctx, base, credentials.ImpersonateOptions{
+		TargetServiceAccount: "target@p.iam.gserviceaccount.com",
+		Delegates:            []string{"delegate@p.iam.gserviceaccount.com"},
+		Lifetime:             15 * time.Minute,
+		Endpoint:             server.URL + "/",
+	})
+	if err != nil {
+		t.Fatalf("Impersonate() error = %v", err)
+	}
+	// tokens are requested after the context passed to Impersonate is done
+	cancel()
+	if creds.ProjectID != "base-project" {
+		t.Errorf("Impersonate() project = %v, want %v", creds.ProjectID, "base-project")
+	}
+	for i := 0; i < 2; i++ {
+		token, err := creds.TokenSource.Token()
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if token.AccessToken != "impersonated-token" || !token.Expiry.Equal(expiry) {
+			t.Errorf("Token() = %v (expires %v), want impersonated-token (expires %v)", token.AccessToken, token.Expiry, expiry)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("generateAccessToken called %d times, want the token to be reused", requests)
+	}
+
+	if _, err := credentials.Impersonate(context.TODO(), base, credentials.ImpersonateOptions{}); err == nil {
+		t.Errorf("Impersonate() without a service account, want error")
+	}
+}
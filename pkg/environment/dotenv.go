@@ -0,0 +1,146 @@
+package environment
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// syntaxError is returned by ParseDotenv for invalid lines. msg may quote
+// the line.
+type syntaxError struct {
+	line int
+	msg  string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// ParseDotenv parses variables in dotenv format:
+//
+//	# comment
+//	export NAME=value
+//	NAME='literal value'
+//	NAME="value with \"escapes\"\n and multiple lines"
+//
+// Unquoted values are trimmed and end at an inline ` #` comment.
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	rest := strings.ReplaceAll(string(data), "\r\n", "\n")
+	for line := 1; rest != ""; line++ {
+		var current string
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			current, rest = rest[:i], rest[i+1:]
+		} else {
+			current, rest = rest, ""
+		}
+		current = strings.TrimSpace(current)
+		if current == "" || strings.HasPrefix(current, "#") {
+			continue
+		}
+		current = strings.TrimPrefix(current, "export ")
+		eq := strings.IndexByte(current, '=')
+		if eq < 1 {
+			return nil, &syntaxError{line: line, msg: "expected NAME=value"}
+		}
+		name := strings.TrimSpace(current[:eq])
+		if !validName(name) {
+			return nil, &syntaxError{line: line, msg: fmt.Sprintf("invalid variable name: '%s'", name)}
+		}
+		value := strings.TrimLeft(current[eq+1:], " \t")
+
+		// quoted values may continue over multiple lines
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			body := value[1:]
+			for !closed(body, quote) {
+				if rest == "" {
+					return nil, &syntaxError{line: line, msg: "unterminated quoted value"}
+				}
+				var next string
+				if i := strings.IndexByte(rest, '\n'); i >= 0 {
+					next, rest = rest[:i], rest[i+1:]
+				} else {
+					next, rest = rest, ""
+				}
+				body += "\n" + next
+				line++
+			}
+			end := closingQuote(body, quote)
+			if trailing := strings.TrimSpace(body[end+1:]); trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return nil, &syntaxError{line: line, msg: "unexpected characters after quoted value"}
+			}
+			body = body[:end]
+			if quote == '"' {
+				body = unescapeDoubleQuoted(body)
+			}
+			env[name] = body
+			continue
+		}
+
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		env[name] = strings.TrimSpace(value)
+	}
+	return env, nil
+}
+
+// validName accepts variable names, as well as the dashes and dots commonly
+// found in the keys of structured secrets.
+func validName(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || i > 0 && (r >= '0' && r <= '9' || r == '-' || r == '.')) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func closed(body string, quote byte) bool {
+	return closingQuote(body, quote) >= 0
+}
+
+// closingQuote returns the index of the unescaped closing quote in body.
+func closingQuote(body string, quote byte) int {
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\\' && quote == '"':
+			i++
+		case body[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDoubleQuoted(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$', '`':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -3,32 +3,46 @@ package environment
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
 
-	"github.com/telia-oss/gcp-env/internal/secrets"
+	"github.com/telia-oss/gcp-env/pkg/credentials"
+	"github.com/telia-oss/gcp-env/pkg/secrets"
 	"golang.org/x/oauth2/google"
 )
 
-const (
-	// The secret should be in the format (optionally with version)
-	// kms://{base64}
-	kmsPrefix = "kms://"
-	// The secret name should be in the format (optionally with version)
-	// `sm://projects/{PROJECT_ID}/secrets/{SECRET_NAME}`
-	// `sm://projects/{PROJECT_ID}/secrets/{SECRET_NAME}/versions/{VERSION|latest}`
-	smPrefix = "sm://"
-)
+// DefaultExpandPrefix marks variables whose secret is expanded into several
+// variables, e.g. GCP_ENV_EXPAND_DB=sm://db-bundle sets DB_HOST, DB_USER, ...
+const DefaultExpandPrefix = "GCP_ENV_EXPAND_"
 
 // Manager handles API calls to AWS.
 type Manager struct {
 	SecretProvider *secrets.Provider
+	// ExpandPrefix overrides DefaultExpandPrefix.
+	ExpandPrefix string
+	// NormalizeKey maps the keys of an expanded secret to variable names
+	// (before the prefix is added). Defaults to NormalizeKey.
+	NormalizeKey func(key string) string
+	// Source and Sink are the variables read and written by Populate.
+	// Both default to the process environment (OSEnv).
+	Source Source
+	Sink   Sink
 }
 
-// New creates a new manager for populating secret values.
-func New(ctx context.Context, creds *google.Credentials) (*Manager, error) {
-	provider, err := secrets.NewClient(ctx, creds)
+// New creates a new manager for populating secret values. ctx is only used to
+// create the API clients, see PopulateContext for passing a context to the
+// API calls. When creds is nil, credentials.FromEnvironment is used. opts
+// configure the API clients, see secrets.NewClient.
+func New(ctx context.Context, creds *google.Credentials, opts ...secrets.ClientOption) (*Manager, error) {
+	if creds == nil {
+		var err error
+		if creds, err = credentials.FromEnvironment(ctx); err != nil {
+			return nil, err
+		}
+	}
+	provider, err := secrets.NewClient(ctx, creds, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -37,44 +51,206 @@ func New(ctx context.Context, creds *google.Credentials) (*Manager, error) {
 	}, nil
 }
 
+// NewWithProvider creates a new manager using provider, e.g. one built with
+// secrets.NewSecretsProvider from custom API clients.
+func NewWithProvider(provider *secrets.Provider) *Manager {
+	return &Manager{
+		SecretProvider: provider,
+	}
+}
+
 // Populate environment variables with their secret values from Secrets manager,
+// reading them from Source and writing the resolved ones to Sink.
 func (m *Manager) Populate() error {
-	env := make(map[string]string)
-	for _, v := range os.Environ() {
-		var (
-			found  bool
-			secret string
-			err    error
-		)
-
-		name, value := parseEnvironmentVariable(v)
-
-		// Improve this with provider factory
-		if strings.HasPrefix(value, kmsPrefix) {
-			secret, err = m.SecretProvider.ResolveSecret(value)
+	return m.PopulateContext(context.Background())
+}
+
+// PopulateContext is like Populate, using ctx for the API calls. Nothing is
+// set if ctx is cancelled or its deadline expires first.
+func (m *Manager) PopulateContext(ctx context.Context) error {
+	var source Source = OSEnv{}
+	if m.Source != nil {
+		source = m.Source
+	}
+	var sink Sink = OSEnv{}
+	if m.Sink != nil {
+		sink = m.Sink
+	}
+
+	env, err := source.Environ()
+	if err != nil {
+		return fmt.Errorf("failed to read environment: %w", err)
+	}
+	resolved, err := m.Resolve(ctx, env)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(resolved))
+	for name := range resolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := sink.Setenv(name, resolved[name]); err != nil {
+			return fmt.Errorf("failed to set environment variable: '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Interpolate replaces the references embedded in value as ${REFERENCE}, see
+// secrets.Template.
+func (m *Manager) Interpolate(value string) (string, error) {
+	return m.SecretProvider.Interpolate(value)
+}
+
+// InterpolateContext is like Interpolate, using ctx for the API calls.
+func (m *Manager) InterpolateContext(ctx context.Context, value string) (string, error) {
+	return m.SecretProvider.InterpolateContext(ctx, value)
+}
+
+// Resolve returns the variables of env that changed after resolving their
+// secret references, including those added by expanded secrets. Values are
+// either a reference, or contain references embedded as ${REFERENCE}. env is
+// not modified. All failures are returned together as a *secrets.MultiError,
+// where the failures of references hold the name of their variable.
+func (m *Manager) Resolve(ctx context.Context, env map[string]string) (map[string]string, error) {
+	expandPrefix := m.ExpandPrefix
+	if expandPrefix == "" {
+		expandPrefix = DefaultExpandPrefix
+	}
+
+	keys := make([]string, 0, len(env))
+	for name := range env {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	var (
+		names []string
+		errs  []error
+	)
+	refsOf := make(map[string][]string)
+	templates := make(map[string]*secrets.Template)
+	literals := make(map[string]string)
+	for _, name := range keys {
+		value := env[name]
+		if m.SecretProvider.Supports(value) {
+			refsOf[name] = []string{value}
+		} else if strings.Contains(value, "${") {
+			t, err := secrets.ParseTemplate(value)
 			if err != nil {
-				return fmt.Errorf("failed to decrypt kms secret: '%s': %s", value, err)
+				errs = append(errs, fmt.Errorf("failed to parse environment variable: '%s': %w", name, err))
+				continue
 			}
-			found = true
-		} else if strings.HasPrefix(value, smPrefix) {
-			secret, err = m.SecretProvider.ResolveSecret(value)
-			if err != nil {
-				return fmt.Errorf("failed to decrypt kms secret: '%s': %s", value, err)
+			// embedded references whose scheme has no resolver are kept as is,
+			// like whole values which are not supported references
+			for _, ref := range t.References() {
+				if m.SecretProvider.Supports(ref) {
+					refsOf[name] = append(refsOf[name], ref)
+				} else {
+					literals[ref] = "${" + ref + "}"
+				}
 			}
-			found = true
+			// values without references are left as is, including "$${"
+			if len(refsOf[name]) == 0 {
+				continue
+			}
+			templates[name] = t
+		} else {
+			continue
 		}
+		names = append(names, name)
 
-		if found {
-			env[name] = secret
+		// check the syntax of every reference before making any API calls
+		for _, ref := range refsOf[name] {
+			if _, err := m.SecretProvider.ParseReference(ref); err != nil {
+				errs = append(errs, fmt.Errorf("failed to parse environment variable: '%s': %w", name, err))
+			}
 		}
 	}
+	if len(errs) > 0 {
+		return nil, &secrets.MultiError{Errors: errs}
+	}
 
-	for name, secret := range env {
-		if err := os.Setenv(name, secret); err != nil {
-			return fmt.Errorf("failed to set environment variable: '%s': %s", name, err)
+	var refs []string
+	for _, name := range names {
+		refs = append(refs, refsOf[name]...)
+	}
+	values, err := m.SecretProvider.ResolveSecretsContext(ctx, refs)
+	failed := make(map[string]*secrets.Error)
+	if err != nil {
+		var multi *secrets.MultiError
+		if !errors.As(err, &multi) {
+			return nil, err
+		}
+		isRef := make(map[string]bool, len(refs))
+		for _, ref := range refs {
+			isRef[ref] = true
+		}
+		for _, err := range multi.Errors {
+			// errors which can't be matched to a variable are fatal, rather
+			// than leaving the variable empty
+			var secretErr *secrets.Error
+			if !errors.As(err, &secretErr) || !isRef[secretErr.Reference] {
+				return nil, multi
+			}
+			failed[secretErr.Reference] = secretErr
 		}
 	}
-	return nil
+	resolved := make(map[string]string, len(refs))
+	for i, ref := range refs {
+		resolved[ref] = values[i]
+	}
+	for ref, literal := range literals {
+		resolved[ref] = literal
+	}
+
+	result := make(map[string]string)
+	var expansions []string
+	secretValues := make(map[string]string, len(names))
+	for _, name := range names {
+		ok := true
+		for _, ref := range refsOf[name] {
+			if secretErr, isFailed := failed[ref]; isFailed {
+				named := *secretErr
+				named.Name = name
+				errs = append(errs, &named)
+				ok = false
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		value := resolved[env[name]]
+		if t, isTemplate := templates[name]; isTemplate {
+			if value, err = t.Execute(resolved); err != nil {
+				errs = append(errs, fmt.Errorf("failed to interpolate environment variable: '%s': %w", name, err))
+				continue
+			}
+		}
+		if strings.HasPrefix(name, expandPrefix) {
+			secretValues[name] = value
+			expansions = append(expansions, name)
+			continue
+		}
+		result[name] = value
+	}
+	for _, name := range expansions {
+		prefix := strings.TrimPrefix(name, expandPrefix)
+		if prefix != "" {
+			prefix += "_"
+		}
+		if err := m.expand(name, prefix, secretValues[name], env, result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &secrets.MultiError{Errors: errs}
+	}
+	return result, nil
 }
 
 func parseEnvironmentVariable(s string) (string, string) {
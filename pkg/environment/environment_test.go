@@ -1,7 +1,590 @@
 package environment_test
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
 
-func TestMain(t *testing.T) {
+	"github.com/googleapis/gax-go/v2"
+	environment "github.com/telia-oss/gcp-env/pkg/environment"
+	"github.com/telia-oss/gcp-env/pkg/secrets"
+	"github.com/telia-oss/gcp-env/pkg/secrets/secretsfakes"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+	secretspb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
+func fakeProvider(payloads map[string]string) *secrets.Provider {
+	fakeSecretManagerAPI := &secretsfakes.FakeGoogleSecretsManagerAPI{}
+	fakeSecretManagerAPI.AccessSecretVersionStub = func(ctx context.Context, req *secretspb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretspb.AccessSecretVersionResponse, error) {
+		return &secretspb.AccessSecretVersionResponse{Payload: &secretspb.SecretPayload{
+			Data: []byte(payloads[req.Name]),
+		}}, nil
+	}
+	return &secrets.Provider{SMClient: fakeSecretManagerAPI}
+}
+
+func setenv(t *testing.T, env map[string]string) {
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+	t.Cleanup(func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestManager_PopulateExpand(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		payload      string
+		normalizeKey func(string) string
+		want         map[string]string
+		wantErr      string
+	}{
+		{
+			name:    "expand JSON secret",
+			env:     map[string]string{"GCP_ENV_EXPAND_TEST_DB": "sm://projects/p/secrets/db"},
+			payload: `{"host":"db","user":"app","password":"s3cr3t","port":5432}`,
+			want: map[string]string{
+				"TEST_DB_HOST":     "db",
+				"TEST_DB_USER":     "app",
+				"TEST_DB_PASSWORD": "s3cr3t",
+				"TEST_DB_PORT":     "5432",
+			},
+		},
+		{
+			name:    "expand dotenv secret",
+			env:     map[string]string{"GCP_ENV_EXPAND_TEST_DB": "sm://projects/p/secrets/db"},
+			payload: "# database\nhost=db\nuser-name='app'\n",
+			want: map[string]string{
+				"TEST_DB_HOST":      "db",
+				"TEST_DB_USER_NAME": "app",
+			},
+		},
+		{
+			name:         "custom key normalisation",
+			env:          map[string]string{"GCP_ENV_EXPAND_TEST_DB": "sm://projects/p/secrets/db"},
+			payload:      `{"Host":"db"}`,
+			normalizeKey: func(key string) string { return key },
+			want:         map[string]string{"TEST_DB_Host": "db"},
+		},
+		{
+			name: "conflict with existing variable",
+			env: map[string]string{
+				"GCP_ENV_EXPAND_TEST_DB": "sm://projects/p/secrets/db",
+				"TEST_DB_HOST":           "localhost",
+			},
+			payload: `{"host":"db","user":"app"}`,
+			wantErr: "TEST_DB_HOST",
+		},
+		{
+			name:    "payload is not structured",
+			env:     map[string]string{"GCP_ENV_EXPAND_TEST_DB": "sm://projects/p/secrets/db"},
+			payload: "s3cr3t",
+			wantErr: "GCP_ENV_EXPAND_TEST_DB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setenv(t, tt.env)
+			m := &environment.Manager{
+				SecretProvider: fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": tt.payload}),
+				NormalizeKey:   tt.normalizeKey,
+			}
+			err := m.Populate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Manager.Populate() error = %v, want error containing %v", err, tt.wantErr)
+				}
+				if os.Getenv("TEST_DB_USER") != "" {
+					t.Errorf("Manager.Populate() set variables despite error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Manager.Populate() error = %v", err)
+			}
+			got := make(map[string]string)
+			for name := range tt.want {
+				got[name] = os.Getenv(name)
+				defer os.Unsetenv(name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Manager.Populate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_PopulateExpandTwice(t *testing.T) {
+	setenv(t, map[string]string{"GCP_ENV_EXPAND_TEST_DB": "sm://projects/p/secrets/db"})
+	defer os.Unsetenv("TEST_DB_HOST")
+	defer os.Unsetenv("TEST_DB_USER")
+
+	payloads := map[string]string{"projects/p/secrets/db/versions/latest": `{"host":"db","user":"app"}`}
+	m := &environment.Manager{SecretProvider: fakeProvider(payloads)}
+	for i := 0; i < 2; i++ {
+		if err := m.Populate(); err != nil {
+			t.Fatalf("Manager.Populate() #%d error = %v", i+1, err)
+		}
+	}
+	if got := os.Getenv("TEST_DB_HOST"); got != "db" {
+		t.Errorf("Manager.Populate() TEST_DB_HOST = %v, want %v", got, "db")
+	}
+
+	// a changed secret still conflicts with the earlier values
+	payloads["projects/p/secrets/db/versions/latest"] = `{"host":"db","user":"admin"}`
+	if err := m.Populate(); !errors.Is(err, environment.ErrConflict) || !strings.Contains(err.Error(), "TEST_DB_USER") {
+		t.Errorf("Manager.Populate() error = %v, want %v for TEST_DB_USER", err, environment.ErrConflict)
+	}
+}
+
+func TestManager_PopulateInterpolate(t *testing.T) {
+	setenv(t, map[string]string{
+		"TEST_DATABASE_URL": "postgres://app:${sm://projects/p/secrets/db}@db:5432/app?opt=$${literal}",
+		"TEST_PASSWORD":     "sm://projects/p/secrets/db",
+		"TEST_PLAIN":        "${HOME}",
+		"TEST_ESCAPED":      "$${HOME} $${sm://projects/p/secrets/db}",
+		"TEST_MIXED":        "$${HOME} ${sm://projects/p/secrets/db}",
+		"TEST_UNSUPPORTED":  "a ${https://host} b",
+		"TEST_PARTIAL":      "${https://host}/${sm://projects/p/secrets/db}",
+	})
+	m := &environment.Manager{
+		SecretProvider: fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": "s3cr3t"}),
+	}
+	if err := m.Populate(); err != nil {
+		t.Fatalf("Manager.Populate() error = %v", err)
+	}
+	want := map[string]string{
+		"TEST_DATABASE_URL": "postgres://app:s3cr3t@db:5432/app?opt=${literal}",
+		"TEST_PASSWORD":     "s3cr3t",
+		"TEST_PLAIN":        "${HOME}",
+		"TEST_ESCAPED":      "$${HOME} $${sm://projects/p/secrets/db}",
+		"TEST_MIXED":        "${HOME} s3cr3t",
+		"TEST_UNSUPPORTED":  "a ${https://host} b",
+		"TEST_PARTIAL":      "${https://host}/s3cr3t",
+	}
+	for name, value := range want {
+		if got := os.Getenv(name); got != value {
+			t.Errorf("Manager.Populate() %s = %v, want %v", name, got, value)
+		}
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	input := `# comment
+PLAIN=value
+export EXPORTED=exported
+SPACED = trimmed value # inline comment
+SINGLE='literal \n $HOME'
+DOUBLE="escaped \"quotes\"\nand newline"
+MULTI="first
+second"
+EMPTY=
+`
+	want := map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "exported",
+		"SPACED":   "trimmed value",
+		"SINGLE":   `literal \n $HOME`,
+		"DOUBLE":   "escaped \"quotes\"\nand newline",
+		"MULTI":    "first\nsecond",
+		"EMPTY":    "",
+	}
+	got, err := environment.ParseDotenv(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseDotenv() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDotenv() = %v, want %v", got, want)
+	}
+
+	for _, invalid := range []string{"NOVALUE", "1NAME=value", "NAME=\"unterminated"} {
+		if _, err := environment.ParseDotenv(strings.NewReader(invalid)); err == nil {
+			t.Errorf("ParseDotenv(%q) error = nil, want error", invalid)
+		}
+	}
+}
+
+func TestManager_Resolve(t *testing.T) {
+	m := &environment.Manager{
+		SecretProvider: fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": "s3cr3t"}),
+	}
+	env := map[string]string{
+		"PASSWORD": "sm://projects/p/secrets/db",
+		"URL":      "postgres://app:${sm://projects/p/secrets/db}@db/app",
+		"PLAIN":    "value",
+	}
+	got, err := m.Resolve(context.TODO(), env)
+	if err != nil {
+		t.Fatalf("Manager.Resolve() error = %v", err)
+	}
+	want := map[string]string{
+		"PASSWORD": "s3cr3t",
+		"URL":      "postgres://app:s3cr3t@db/app",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Manager.Resolve() = %v, want %v", got, want)
+	}
+	if env["PASSWORD"] != "sm://projects/p/secrets/db" {
+		t.Errorf("Manager.Resolve() modified its input")
+	}
+}
+
+func TestManager_ResolveInvalidReference(t *testing.T) {
+	fakeSecretManagerAPI := &secretsfakes.FakeGoogleSecretsManagerAPI{}
+	m := &environment.Manager{
+		SecretProvider: &secrets.Provider{SMClient: fakeSecretManagerAPI},
+	}
+	_, err := m.Resolve(context.TODO(), map[string]string{
+		"PASSWORD": "sm://projects/p/secrets/db",
+		"URL":      "postgres://app:${sm://projects/p/secrets/db/versions/0}@db/app",
+	})
+	want := "failed to parse environment variable: 'URL': invalid reference: invalid secret version: '0'"
+	if err == nil || err.Error() != want {
+		t.Errorf("Manager.Resolve() error = %v, want %v", err, want)
+	}
+	if fakeSecretManagerAPI.AccessSecretVersionCallCount() != 0 {
+		t.Errorf("Manager.Resolve() called the API before checking all references")
+	}
+}
+
+func TestManager_ResolveErrors(t *testing.T) {
+	fakeSecretManagerAPI := &secretsfakes.FakeGoogleSecretsManagerAPI{}
+	fakeSecretManagerAPI.AccessSecretVersionReturns(nil, status.Error(codes.PermissionDenied, "permission denied"))
+	m := &environment.Manager{
+		SecretProvider: &secrets.Provider{SMClient: fakeSecretManagerAPI},
+	}
+	_, err := m.Resolve(context.TODO(), map[string]string{
+		"URL": "postgres://app:${sm://projects/p/secrets/db}@db/app",
+	})
+	if !errors.Is(err, secrets.ErrPermissionDenied) {
+		t.Fatalf("Manager.Resolve() error = %v, want %v", err, secrets.ErrPermissionDenied)
+	}
+	var secretErr *secrets.Error
+	if !errors.As(err, &secretErr) || secretErr.Name != "URL" || secretErr.Reference != "sm://projects/p/secrets/db" {
+		t.Errorf("Manager.Resolve() error = %+v, want a *secrets.Error for URL", err)
+	}
+
+	m.SecretProvider = fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": `{"host":"db"}`})
+	_, err = m.Resolve(context.TODO(), map[string]string{
+		"GCP_ENV_EXPAND_DB": "sm://projects/p/secrets/db",
+		"DB_HOST":           "localhost",
+	})
+	if !errors.Is(err, environment.ErrConflict) {
+		t.Errorf("Manager.Resolve() error = %v, want %v", err, environment.ErrConflict)
+	}
+}
+
+func TestManager_ResolveAllErrors(t *testing.T) {
+	fakeSecretManagerAPI := &secretsfakes.FakeGoogleSecretsManagerAPI{}
+	fakeSecretManagerAPI.AccessSecretVersionStub = func(ctx context.Context, req *secretspb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretspb.AccessSecretVersionResponse, error) {
+		if req.Name == "projects/p/secrets/missing/versions/latest" {
+			return nil, status.Error(codes.NotFound, "secret not found")
+		}
+		return &secretspb.AccessSecretVersionResponse{Payload: &secretspb.SecretPayload{Data: []byte(`{"host":"db"}`)}}, nil
+	}
+	m := &environment.Manager{
+		SecretProvider: &secrets.Provider{SMClient: fakeSecretManagerAPI},
+	}
+	_, err := m.Resolve(context.TODO(), map[string]string{
+		"A_PASSWORD":        "sm://projects/p/secrets/missing",
+		"B_URL":             "postgres://app:${sm://projects/p/secrets/missing}@db/app",
+		"C_PASSWORD":        "sm://projects/p/secrets/db",
+		"GCP_ENV_EXPAND_DB": "sm://projects/p/secrets/db",
+		"DB_HOST":           "localhost",
+	})
+	var multi *secrets.MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("Manager.Resolve() error = %v, want a *secrets.MultiError", err)
+	}
+	var got []string
+	for _, err := range multi.Errors {
+		var secretErr *secrets.Error
+		if errors.As(err, &secretErr) {
+			got = append(got, secretErr.Name)
+			continue
+		}
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"A_PASSWORD",
+		"B_URL",
+		"failed to expand secret: 'GCP_ENV_EXPAND_DB': variables already set: 'DB_HOST'",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Manager.Resolve() errors = %q, want %q", got, want)
+	}
+}
+
+func TestManager_ResolveDelegatedErrors(t *testing.T) {
+	fakeSecretManagerAPI := &secretsfakes.FakeGoogleSecretsManagerAPI{}
+	fakeSecretManagerAPI.AccessSecretVersionReturns(&secretspb.AccessSecretVersionResponse{Payload: &secretspb.SecretPayload{Data: []byte("not hex")}}, nil)
+	p := &secrets.Provider{SMClient: fakeSecretManagerAPI}
+	p.Register("alias", secrets.ResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		return p.ResolveSecretContext(ctx, "sm://projects/p/secrets/db?encoding=hex")
+	}))
+	m := environment.NewWithProvider(p)
+
+	got, err := m.Resolve(context.TODO(), map[string]string{"A": "alias://x"})
+	var secretErr *secrets.Error
+	if !errors.As(err, &secretErr) || secretErr.Name != "A" || secretErr.Reference != "alias://x" {
+		t.Fatalf("Manager.Resolve() = %v, %v, want a *secrets.Error for A", got, err)
+	}
+	if !errors.Is(err, secrets.ErrInvalidPayload) {
+		t.Errorf("Manager.Resolve() error = %v, want %v", err, secrets.ErrInvalidPayload)
+	}
+}
+
+func TestManager_RedactedErrors(t *testing.T) {
+	tests := []struct {
+		description string
+		payload     string
+	}{
+		{description: "invalid variable name", payload: "c2VjcmV0+/aGVsbG8=="},
+		{description: "invalid second line", payload: "USER=app\nhunter2"},
+		{description: "unterminated quote", payload: "PASSWORD='hunter2"},
+		{description: "characters after quote", payload: "PASSWORD='s3cr3t'hunter2"},
+		{description: "invalid json", payload: `{"password":"hunter2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			m := environment.NewWithProvider(fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": tt.payload}))
+			_, err := m.Resolve(context.TODO(), map[string]string{"GCP_ENV_EXPAND_DB": "sm://projects/p/secrets/db"})
+			if !errors.Is(err, secrets.ErrInvalidPayload) {
+				t.Fatalf("Manager.Resolve() error = %v, want %v", err, secrets.ErrInvalidPayload)
+			}
+			for _, secret := range []string{"c2VjcmV0", "aGVsbG8", "hunter2", "s3cr3t"} {
+				if strings.Contains(err.Error(), secret) {
+					t.Errorf("Manager.Resolve() error = %v, contains secret material %q", err, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestManager_PopulateContext(t *testing.T) {
+	setenv(t, map[string]string{"TEST_PASSWORD": "sm://projects/p/secrets/db"})
+	m := &environment.Manager{
+		SecretProvider: fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": "s3cr3t"}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.PopulateContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Manager.PopulateContext() error = %v, want %v", err, context.Canceled)
+	}
+	if got := os.Getenv("TEST_PASSWORD"); got != "sm://projects/p/secrets/db" {
+		t.Errorf("Manager.PopulateContext() set TEST_PASSWORD = %v after cancellation", got)
+	}
+
+	if err := m.PopulateContext(context.Background()); err != nil {
+		t.Fatalf("Manager.PopulateContext() error = %v", err)
+	}
+	if got := os.Getenv("TEST_PASSWORD"); got != "s3cr3t" {
+		t.Errorf("Manager.PopulateContext() set TEST_PASSWORD = %v, want %v", got, "s3cr3t")
+	}
+}
+
+func TestManager_PopulateSourceSink(t *testing.T) {
+	env := environment.Env{
+		"PASSWORD": "sm://projects/p/secrets/db",
+		"PLAIN":    "value",
+	}
+	m := &environment.Manager{
+		SecretProvider: fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": "s3cr3t"}),
+		Source:         env,
+		Sink:           env,
+	}
+	if err := m.Populate(); err != nil {
+		t.Fatalf("Manager.Populate() error = %v", err)
+	}
+	want := environment.Env{"PASSWORD": "s3cr3t", "PLAIN": "value"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("Manager.Populate() = %v, want %v", env, want)
+	}
+	if got := os.Getenv("PASSWORD"); got != "" {
+		t.Errorf("Manager.Populate() modified the process environment: PASSWORD=%v", got)
+	}
+	if got, want := env.List(), []string{"PASSWORD=s3cr3t", "PLAIN=value"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Env.List() = %v, want %v", got, want)
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Setenv(name, value string) error {
+	return errors.New("read-only")
+}
+
+type failingSource struct{}
+
+func (failingSource) Environ() (map[string]string, error) {
+	return nil, errors.New("unreadable")
+}
+
+func TestManager_PopulateSourceSinkErrors(t *testing.T) {
+	m := &environment.Manager{
+		SecretProvider: fakeProvider(map[string]string{"projects/p/secrets/db/versions/latest": "s3cr3t"}),
+		Source:         environment.Env{"PASSWORD": "sm://projects/p/secrets/db"},
+		Sink:           failingSink{},
+	}
+	want := "failed to set environment variable: 'PASSWORD': read-only"
+	if err := m.Populate(); err == nil || err.Error() != want {
+		t.Errorf("Manager.Populate() error = %v, want %v", err, want)
+	}
+
+	m.Source = failingSource{}
+	want = "failed to read environment: unreadable"
+	if err := m.Populate(); err == nil || err.Error() != want {
+		t.Errorf("Manager.Populate() error = %v, want %v", err, want)
+	}
+}
+
+func TestOSEnv(t *testing.T) {
+	setenv(t, map[string]string{"TEST_OS_ENV": "a=b"})
+	env, err := environment.OSEnv{}.Environ()
+	if err != nil || env["TEST_OS_ENV"] != "a=b" {
+		t.Errorf("OSEnv.Environ() = %v, %v, want TEST_OS_ENV=a=b", env["TEST_OS_ENV"], err)
+	}
+	if err := (environment.OSEnv{}).Setenv("TEST_OS_ENV", "c"); err != nil || os.Getenv("TEST_OS_ENV") != "c" {
+		t.Errorf("OSEnv.Setenv() = %v, TEST_OS_ENV=%v, want c", err, os.Getenv("TEST_OS_ENV"))
+	}
+}
+
+func TestNewWithProvider(t *testing.T) {
+	fakeKeyManagementAPI := &secretsfakes.FakeGoogleKeyManagementAPI{}
+	fakeKeyManagementAPI.DecryptReturns(&kmspb.DecryptResponse{Plaintext: []byte("s3cr3t")}, nil)
+	provider := secrets.NewSecretsProvider(context.TODO(), fakeKeyManagementAPI, &secretsfakes.FakeGoogleSecretsManagerAPI{})
+
+	m := environment.NewWithProvider(provider)
+	got, err := m.Resolve(context.TODO(), map[string]string{
+		"PASSWORD": "kms://projects/p/locations/global/keyRings/r/cryptoKeys/k?ciphertext=Y2lwaGVy",
+	})
+	if err != nil {
+		t.Fatalf("Manager.Resolve() error = %v", err)
+	}
+	if want := map[string]string{"PASSWORD": "s3cr3t"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Manager.Resolve() = %v, want %v", got, want)
+	}
+}
+
+func TestExport(t *testing.T) {
+	env := map[string]string{
+		"MULTI":  "line 1\nline 2",
+		"QUOTES": `it's "quoted" $HOME`,
+	}
+	tests := []struct {
+		format environment.Format
+		want   string
+	}{
+		{
+			format: environment.FormatShell,
+			want:   "export MULTI='line 1\nline 2'\nexport QUOTES='it'\\''s \"quoted\" $HOME'\n",
+		},
+		{
+			format: environment.FormatDotenv,
+			want:   "MULTI=\"line 1\\nline 2\"\nQUOTES=\"it's \\\"quoted\\\" \\$HOME\"\n",
+		},
+		{
+			format: environment.FormatSystemd,
+			want:   "MULTI=\"line 1\nline 2\"\nQUOTES=\"it's \\\"quoted\\\" \\$HOME\"\n",
+		},
+		{
+			format: environment.FormatJSON,
+			want:   "{\n  \"MULTI\": \"line 1\\nline 2\",\n  \"QUOTES\": \"it's \\\"quoted\\\" $HOME\"\n}\n",
+		},
+		{
+			format: environment.FormatYAML,
+			want:   "\"MULTI\": \"line 1\\nline 2\"\n\"QUOTES\": \"it's \\\"quoted\\\" $HOME\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := environment.Export(&buf, tt.format, env); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Export() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// dotenv output can be read back
+	var buf bytes.Buffer
+	if err := environment.Export(&buf, environment.FormatDotenv, env); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	got, err := environment.ParseDotenv(&buf)
+	if err != nil {
+		t.Fatalf("ParseDotenv() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, env) {
+		t.Errorf("ParseDotenv(Export()) = %v, want %v", got, env)
+	}
+
+	if err := environment.Export(&buf, environment.FormatShell, map[string]string{"INVALID-NAME": "value"}); err == nil {
+		t.Errorf("Export() error = nil, want error for invalid name")
+	}
+}
+
+func TestManager_Validate(t *testing.T) {
+	fakeSecretManagerAPI := &secretsfakes.FakeGoogleSecretsManagerAPI{}
+	fakeSecretManagerAPI.GetSecretVersionStub = func(ctx context.Context, req *secretspb.GetSecretVersionRequest, opts ...gax.CallOption) (*secretspb.SecretVersion, error) {
+		if req.Name == "projects/p/secrets/missing/versions/latest" {
+			return nil, status.Error(codes.NotFound, "secret version not found")
+		}
+		return &secretspb.SecretVersion{Name: req.Name, State: secretspb.SecretVersion_ENABLED}, nil
+	}
+	fakeKeyManagementAPI := &secretsfakes.FakeGoogleKeyManagementAPI{}
+	fakeKeyManagementAPI.DecryptReturns(&kmspb.DecryptResponse{Plaintext: []byte("s3cr3t")}, nil)
+	m := &environment.Manager{
+		SecretProvider: &secrets.Provider{SMClient: fakeSecretManagerAPI, KMSClient: fakeKeyManagementAPI},
+	}
+
+	got := m.Validate(context.TODO(), map[string]string{
+		"PASSWORD": "sm://projects/p/secrets/db?default=hunter2",
+		"MISSING":  "sm://projects/p/secrets/missing",
+		"URL":      "postgres://app:${kms://projects/p/locations/global/keyRings/r/cryptoKeys/k?ciphertext=Y2lwaGVy}@db/app",
+		"INVALID":  "sm://projects/p/secrets/db/versions/v1",
+		"PLAIN":    "value",
+		"LINK":     "see ${https://host/docs}",
+	})
+	want := []environment.ValidationResult{
+		{
+			Name:       "INVALID",
+			References: []string{"sm://projects/p/secrets/db/versions/v1"},
+			Error:      "invalid reference: invalid secret version: 'v1'",
+		},
+		{
+			Name:       "MISSING",
+			References: []string{"sm://projects/p/secrets/missing"},
+			Error:      "failed to get secret version from Google Secret Manager: rpc error: code = NotFound desc = secret version not found",
+		},
+		{
+			Name:       "PASSWORD",
+			References: []string{"sm://projects/p/secrets/db?default=REDACTED"},
+			OK:         true,
+		},
+		{
+			Name:       "URL",
+			References: []string{"kms://projects/p/locations/global/keyRings/r/cryptoKeys/k?ciphertext=sha256:7bf46297"},
+			OK:         true,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Manager.Validate() = %+v, want %+v", got, want)
+	}
+	if fakeSecretManagerAPI.AccessSecretVersionCallCount() != 0 {
+		t.Errorf("Manager.Validate() accessed the secret payload")
+	}
 }
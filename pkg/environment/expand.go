@@ -0,0 +1,106 @@
+package environment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/telia-oss/gcp-env/pkg/secrets"
+)
+
+// ErrConflict is returned when an expanded secret would overwrite variables
+// which are already set.
+var ErrConflict = errors.New("variables already set")
+
+// NormalizeKey upper-cases key and replaces every character which is not a
+// letter, digit or underscore with an underscore, e.g. "db-host" => "DB_HOST".
+func NormalizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return unicode.ToUpper(r)
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// expand adds a variable to result for each key of the JSON or dotenv payload.
+// Variables which are already set in env or result are reported as conflicts,
+// unless env holds the expanded value already, e.g. after an earlier Populate.
+func (m *Manager) expand(name, prefix, payload string, env, result map[string]string) error {
+	values, err := parseStructuredSecret(payload)
+	if err != nil {
+		return fmt.Errorf("failed to expand secret: '%s': %w", name, err)
+	}
+	normalize := m.NormalizeKey
+	if normalize == nil {
+		normalize = NormalizeKey
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var conflicts []string
+	expanded := make(map[string]string, len(values))
+	for _, key := range keys {
+		variable := prefix + normalize(key)
+		current, inEnv := env[variable]
+		_, inResult := result[variable]
+		_, inExpanded := expanded[variable]
+		if inEnv && current == values[key] {
+			inEnv = false
+		}
+		if inEnv || inResult || inExpanded {
+			conflicts = append(conflicts, variable)
+			continue
+		}
+		expanded[variable] = values[key]
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("failed to expand secret: '%s': %w: '%s'", name, ErrConflict, strings.Join(conflicts, ","))
+	}
+	for variable, value := range expanded {
+		result[variable] = value
+	}
+	return nil
+}
+
+// parseStructuredSecret parses a payload holding either a JSON object or
+// dotenv formatted variables.
+func parseStructuredSecret(payload string) (map[string]string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		values, err := ParseDotenv(strings.NewReader(payload))
+		if err != nil {
+			// only the line number is reported, the line holds secret material
+			var syntaxErr *syntaxError
+			if errors.As(err, &syntaxErr) {
+				return nil, fmt.Errorf("%w: neither a JSON object nor dotenv: line %d", secrets.ErrInvalidPayload, syntaxErr.line)
+			}
+			return nil, fmt.Errorf("%w: neither a JSON object nor dotenv", secrets.ErrInvalidPayload)
+		}
+		return values, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &object); err != nil {
+		return nil, fmt.Errorf("%w: not a valid JSON object", secrets.ErrInvalidPayload)
+	}
+	values := make(map[string]string, len(object))
+	for key, raw := range object {
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			values[key] = str
+			continue
+		}
+		values[key] = string(raw)
+	}
+	return values, nil
+}
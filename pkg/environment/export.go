@@ -0,0 +1,92 @@
+package environment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Format of exported variables.
+type Format string
+
+const (
+	// FormatShell writes POSIX shell `export NAME='value'` lines.
+	FormatShell Format = "shell"
+	// FormatDotenv writes `NAME="value"` lines, as read by ParseDotenv.
+	FormatDotenv Format = "dotenv"
+	// FormatJSON writes a JSON object.
+	FormatJSON Format = "json"
+	// FormatYAML writes a YAML mapping.
+	FormatYAML Format = "yaml"
+	// FormatSystemd writes a systemd EnvironmentFile.
+	FormatSystemd Format = "systemd"
+)
+
+// variableName matches names which are safe to use in shell, dotenv and systemd files.
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Export writes env to w in the given format, sorted by name.
+func Export(w io.Writer, format Format, env map[string]string) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(env); err != nil {
+			return err
+		}
+	case FormatYAML:
+		if len(names) == 0 {
+			buf.WriteString("{}\n")
+		}
+		// JSON strings are valid double-quoted YAML scalars
+		for _, name := range names {
+			fmt.Fprintf(&buf, "%s: %s\n", jsonString(name), jsonString(env[name]))
+		}
+	case FormatShell, FormatDotenv, FormatSystemd:
+		for _, name := range names {
+			if !variableName.MatchString(name) {
+				return fmt.Errorf("invalid variable name for %s format: '%s'", format, name)
+			}
+			switch format {
+			case FormatShell:
+				fmt.Fprintf(&buf, "export %s='%s'\n", name, strings.ReplaceAll(env[name], "'", `'\''`))
+			case FormatDotenv:
+				fmt.Fprintf(&buf, "%s=\"%s\"\n", name, dotenvEscaper.Replace(env[name]))
+			case FormatSystemd:
+				fmt.Fprintf(&buf, "%s=\"%s\"\n", name, systemdEscaper.Replace(env[name]))
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format: '%s'", format)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var (
+	// dotenvEscaper is the inverse of unescapeDoubleQuoted
+	dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	// systemdEscaper escapes the characters systemd treats specially in
+	// double quotes, newlines are kept as is.
+	systemdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+)
+
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
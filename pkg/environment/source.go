@@ -0,0 +1,68 @@
+package environment
+
+import (
+	"os"
+	"sort"
+)
+
+// Source provides the variables resolved by Manager.Populate.
+type Source interface {
+	Environ() (map[string]string, error)
+}
+
+// Sink receives the variables resolved by Manager.Populate.
+type Sink interface {
+	Setenv(name, value string) error
+}
+
+// OSEnv is the Source and Sink of the process environment, used by default.
+type OSEnv struct{}
+
+// Environ returns the variables of the process environment.
+func (OSEnv) Environ() (map[string]string, error) {
+	env := make(map[string]string)
+	for _, v := range os.Environ() {
+		name, value := parseEnvironmentVariable(v)
+		env[name] = value
+	}
+	return env, nil
+}
+
+// Setenv sets a variable of the process environment.
+func (OSEnv) Setenv(name, value string) error {
+	return os.Setenv(name, value)
+}
+
+// Env is a Source and Sink backed by a map, e.g. to build the environment of
+// a child process without modifying the process environment.
+type Env map[string]string
+
+// Environ returns a copy of the variables.
+func (e Env) Environ() (map[string]string, error) {
+	env := make(map[string]string, len(e))
+	for name, value := range e {
+		env[name] = value
+	}
+	return env, nil
+}
+
+// Setenv sets a variable.
+func (e Env) Setenv(name, value string) error {
+	e[name] = value
+	return nil
+}
+
+// List returns the variables as NAME=VALUE pairs, sorted by name, as used by
+// os/exec.Cmd.Env and syscall.Exec.
+func (e Env) List() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = name + "=" + e[name]
+	}
+	return list
+}
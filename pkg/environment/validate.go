@@ -0,0 +1,68 @@
+package environment
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/telia-oss/gcp-env/pkg/secrets"
+)
+
+// ValidationResult is the outcome of validating the references of a variable.
+// It holds no secret material: references are redacted (see secrets.Redact).
+type ValidationResult struct {
+	Name       string   `json:"name"`
+	References []string `json:"references"`
+	OK         bool     `json:"ok"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Validate checks that every reference in env resolves, without fetching the
+// secrets where the resolver allows it. Results are sorted by name.
+func (m *Manager) Validate(ctx context.Context, env map[string]string) []ValidationResult {
+	var results []ValidationResult
+	for name, value := range env {
+		var refs []string
+		if m.SecretProvider.Supports(value) {
+			refs = []string{value}
+		} else if strings.Contains(value, "${") {
+			t, err := secrets.ParseTemplate(value)
+			if err != nil {
+				results = append(results, ValidationResult{Name: name, Error: err.Error()})
+				continue
+			}
+			for _, ref := range t.References() {
+				if m.SecretProvider.Supports(ref) {
+					refs = append(refs, ref)
+				}
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		result := ValidationResult{Name: name, OK: true}
+		for _, ref := range refs {
+			result.References = append(result.References, secrets.Redact(ref))
+			if _, err := m.SecretProvider.ParseReference(ref); err != nil && result.OK {
+				result.OK = false
+				result.Error = err.Error()
+			}
+		}
+		// references are only checked against the APIs once they all parse
+		for _, ref := range refs {
+			if !result.OK {
+				break
+			}
+			if err := m.SecretProvider.ValidateSecretContext(ctx, ref); err != nil {
+				result.OK = false
+				result.Error = err.Error()
+			}
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
@@ -0,0 +1,113 @@
+package gcpenvtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Fixture is the initial content of a Server, e.g.
+//
+//	project: my-project
+//	secrets:
+//	  api-key: s3cr3t
+//	  db-password:
+//	    - old
+//	    - value: new
+//	      disabled: true
+//	keys:
+//	  - projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/my-key
+type Fixture struct {
+	// Project is used for the secrets not named projects/{PROJECT}/secrets/{SECRET}.
+	Project string `yaml:"project"`
+	// Secrets maps secret names to their versions, oldest first. The version
+	// numbers start at 1.
+	Secrets map[string]Versions `yaml:"secrets"`
+	// Keys are the KMS crypto keys:
+	// projects/{PROJECT}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}
+	Keys []string `yaml:"keys"`
+}
+
+// Versions are the versions of a secret. In YAML, a single value is a secret
+// with one version.
+type Versions []Version
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *Versions) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var version Version
+	if err := unmarshal(&version); err == nil {
+		*v = Versions{version}
+		return nil
+	}
+	var versions []Version
+	if err := unmarshal(&versions); err != nil {
+		return err
+	}
+	*v = versions
+	return nil
+}
+
+// Version is a secret version. In YAML, a single value is an enabled version.
+type Version struct {
+	Value    string `yaml:"value"`
+	Disabled bool   `yaml:"disabled"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *Version) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*v = Version{Value: value}
+		return nil
+	}
+	type version Version
+	return unmarshal((*version)(v))
+}
+
+// FixtureFromMap returns a fixture of secrets with a single enabled version,
+// e.g. {"projects/p/secrets/db-password": "s3cr3t"}.
+func FixtureFromMap(secrets map[string]string) *Fixture {
+	f := &Fixture{Secrets: make(map[string]Versions, len(secrets))}
+	for name, value := range secrets {
+		f.Secrets[name] = Versions{{Value: value}}
+	}
+	return f
+}
+
+// ParseFixture parses a YAML fixture.
+func ParseFixture(data []byte) (*Fixture, error) {
+	var f Fixture
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture: %w", err)
+	}
+	return &f, nil
+}
+
+// ReadFixture reads a YAML fixture from a file.
+func ReadFixture(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	return ParseFixture(data)
+}
+
+// secretName returns the resource name of a secret of the fixture.
+func (f *Fixture) secretName(name string) (string, error) {
+	if strings.HasPrefix(name, "projects/") {
+		if !secretName.MatchString(name) {
+			return "", fmt.Errorf("invalid secret name: '%s'", name)
+		}
+		return name, nil
+	}
+	if f.Project == "" {
+		return "", fmt.Errorf("invalid secret name: '%s': expected projects/{PROJECT}/secrets/{SECRET} without a fixture project", name)
+	}
+	name = "projects/" + f.Project + "/secrets/" + name
+	if !secretName.MatchString(name) {
+		return "", fmt.Errorf("invalid secret name: '%s'", name)
+	}
+	return name, nil
+}
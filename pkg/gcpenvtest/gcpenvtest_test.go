@@ -0,0 +1,177 @@
+package gcpenvtest_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/telia-oss/gcp-env/pkg/gcpenvtest"
+	"github.com/telia-oss/gcp-env/pkg/secrets"
+)
+
+const (
+	key      = "projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/my-key"
+	otherKey = "projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/other-key"
+)
+
+func newProvider(t *testing.T, fixture *gcpenvtest.Fixture) (*gcpenvtest.Server, *secrets.Provider) {
+	t.Helper()
+	server, err := gcpenvtest.NewServer(fixture)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	t.Cleanup(server.Close)
+	provider, err := secrets.NewClient(context.Background(), nil, server.ClientOptions()...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	provider.ProjectID = server.Project
+	provider.Retry = &secrets.RetryPolicy{MaxAttempts: 1}
+	return server, provider
+}
+
+func TestServer_SecretManager(t *testing.T) {
+	fixture, err := gcpenvtest.ReadFixture("testdata/fixture.yaml")
+	if err != nil {
+		t.Fatalf("ReadFixture() error = %v", err)
+	}
+	server, provider := newProvider(t, fixture)
+	ctx := context.Background()
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr error
+	}{
+		{ref: "sm://api-key", want: "s3cr3t"},
+		{ref: "sm://projects/my-project/secrets/api-key/versions/1", want: "s3cr3t"},
+		{ref: "sm://db-password#1", want: "old"},
+		{ref: "sm://db-password#2", wantErr: secrets.ErrSecretDisabled},
+		{ref: "sm://db-password", wantErr: secrets.ErrSecretDisabled},
+		{ref: "sm://db-password#3", wantErr: secrets.ErrSecretNotFound},
+		{ref: "sm://projects/other-project/secrets/token", want: "second"},
+		{ref: "sm://projects/other-project/secrets/token/versions/1", wantErr: secrets.ErrSecretDisabled},
+		{ref: "sm://missing", wantErr: secrets.ErrSecretNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := provider.ResolveSecretContext(ctx, tt.ref)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SecretsProvider.ResolveSecretContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SecretsProvider.ResolveSecretContext() = %v, want %v", got, tt.want)
+			}
+			if err := provider.ValidateSecretContext(ctx, tt.ref); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SecretsProvider.ValidateSecretContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	version, err := server.AddSecretVersion("projects/my-project/secrets/db-password", []byte("newer"))
+	if err != nil || version != "projects/my-project/secrets/db-password/versions/3" {
+		t.Fatalf("Server.AddSecretVersion() = %v, %v, want %v", version, err, "projects/my-project/secrets/db-password/versions/3")
+	}
+	if got, err := provider.ResolveSecretContext(ctx, "sm://db-password"); err != nil || got != "newer" {
+		t.Errorf("SecretsProvider.ResolveSecretContext() after AddSecretVersion = %v, %v, want %v", got, err, "newer")
+	}
+	if err := server.EnableSecretVersion("projects/my-project/secrets/db-password/versions/2"); err != nil {
+		t.Fatalf("Server.EnableSecretVersion() error = %v", err)
+	}
+	if got, err := provider.ResolveSecretContext(ctx, "sm://db-password#2"); err != nil || got != "new" {
+		t.Errorf("SecretsProvider.ResolveSecretContext() after EnableSecretVersion = %v, %v, want %v", got, err, "new")
+	}
+}
+
+func TestServer_KMS(t *testing.T) {
+	server, provider := newProvider(t, &gcpenvtest.Fixture{Keys: []string{key, otherKey}})
+	ctx := context.Background()
+
+	ref, err := provider.EncryptContext(ctx, key, []byte("s3cr3t"))
+	if err != nil {
+		t.Fatalf("SecretsProvider.EncryptContext() error = %v", err)
+	}
+	ciphertext, err := server.Encrypt(key, []byte("s3cr3t"))
+	if err != nil {
+		t.Fatalf("Server.Encrypt() error = %v", err)
+	}
+	if want := "kms://" + key + "?ciphertext=" + base64.StdEncoding.EncodeToString(ciphertext); ref != want {
+		t.Errorf("SecretsProvider.EncryptContext() = %v, want deterministic %v", ref, want)
+	}
+	if got, err := provider.ResolveSecretContext(ctx, ref); err != nil || got != "s3cr3t" {
+		t.Errorf("SecretsProvider.ResolveSecretContext() = %v, %v, want %v", got, err, "s3cr3t")
+	}
+
+	wrongKey := "kms://" + otherKey + "?ciphertext=" + base64.StdEncoding.EncodeToString(ciphertext)
+	if _, err := provider.ResolveSecretContext(ctx, wrongKey); err == nil {
+		t.Error("SecretsProvider.ResolveSecretContext() with the wrong key succeeded, want error")
+	}
+	missingKey := "projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/missing"
+	if _, err := provider.EncryptContext(ctx, missingKey, []byte("s3cr3t")); !errors.Is(err, secrets.ErrSecretNotFound) {
+		t.Errorf("SecretsProvider.EncryptContext() error = %v, wantErr %v", err, secrets.ErrSecretNotFound)
+	}
+}
+
+func TestServer_Env(t *testing.T) {
+	server, err := gcpenvtest.NewServer(gcpenvtest.FixtureFromMap(map[string]string{
+		"projects/p/secrets/db-password": "s3cr3t",
+	}))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	defer server.Close()
+
+	env := server.Env()
+	if len(env) != 2 {
+		t.Fatalf("Server.Env() = %v, want the two endpoints", env)
+	}
+	os.Setenv("GCP_ENV_SM_ENDPOINT", server.Addr)
+	defer os.Unsetenv("GCP_ENV_SM_ENDPOINT")
+	provider, err := secrets.NewClient(context.Background(), nil, secrets.WithInsecure())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if got, err := provider.ResolveSecretContext(context.Background(), "sm://projects/p/secrets/db-password"); err != nil || got != "s3cr3t" {
+		t.Errorf("SecretsProvider.ResolveSecretContext() = %v, %v, want %v", got, err, "s3cr3t")
+	}
+}
+
+func TestParseFixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    gcpenvtest.Versions
+		wantErr bool
+	}{
+		{name: "value", data: "secrets: {a: x}", want: gcpenvtest.Versions{{Value: "x"}}},
+		{name: "version", data: "secrets: {a: {value: x, disabled: true}}", want: gcpenvtest.Versions{{Value: "x", Disabled: true}}},
+		{name: "versions", data: "secrets: {a: [x, {value: y, disabled: true}]}", want: gcpenvtest.Versions{{Value: "x"}, {Value: "y", Disabled: true}}},
+		{name: "unknown field", data: "secret: {a: x}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gcpenvtest.ParseFixture([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFixture() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			versions := got.Secrets["a"]
+			if len(versions) != len(tt.want) {
+				t.Fatalf("ParseFixture() versions = %v, want %v", versions, tt.want)
+			}
+			for i := range versions {
+				if versions[i] != tt.want[i] {
+					t.Errorf("ParseFixture() versions = %v, want %v", versions, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := gcpenvtest.NewServer(&gcpenvtest.Fixture{Secrets: map[string]gcpenvtest.Versions{"a": {{Value: "x"}}}}); err == nil {
+		t.Error("NewServer() with a short secret name and no project succeeded, want error")
+	}
+}
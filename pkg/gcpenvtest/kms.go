@@ -0,0 +1,102 @@
+package gcpenvtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tagSize is the length of the key tag prefixed to the ciphertexts.
+const tagSize = 8
+
+// AddKey adds a crypto key
+// (projects/{PROJECT}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}).
+func (s *Server) AddKey(name string) error {
+	if !cryptoKeyName.MatchString(name) {
+		return status.Errorf(codes.InvalidArgument, "invalid crypto key name: '%s'", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[name] = true
+	return nil
+}
+
+// Encrypt encrypts plaintext with the crypto key, like the KMS API of the
+// server. The ciphertext only depends on the key name and plaintext, e.g. to
+// build kms:// references for fixtures.
+func (s *Server) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	if err := s.checkKey(key); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return append(sum[:tagSize], xorKeyStream(key, plaintext)...), nil
+}
+
+// Decrypt decrypts a ciphertext returned by Encrypt.
+func (s *Server) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	if err := s.checkKey(key); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(key))
+	if len(ciphertext) < tagSize || !bytes.Equal(ciphertext[:tagSize], sum[:tagSize]) {
+		return nil, status.Error(codes.InvalidArgument, "Decryption failed: the ciphertext is invalid.")
+	}
+	return xorKeyStream(key, ciphertext[tagSize:]), nil
+}
+
+func (s *Server) checkKey(key string) error {
+	if !cryptoKeyName.MatchString(key) {
+		return status.Errorf(codes.InvalidArgument, "invalid crypto key name: '%s'", key)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.keys[key] {
+		return status.Errorf(codes.NotFound, "CryptoKey %s not found.", key)
+	}
+	return nil
+}
+
+// xorKeyStream XORs data with SHA-256(key, counter) blocks.
+func xorKeyStream(key string, data []byte) []byte {
+	out := make([]byte, len(data))
+	var block [sha256.Size]byte
+	for i := range data {
+		if i%sha256.Size == 0 {
+			counter := make([]byte, 8)
+			binary.BigEndian.PutUint64(counter, uint64(i/sha256.Size))
+			block = sha256.Sum256(append([]byte(key), counter...))
+		}
+		out[i] = data[i] ^ block[i%sha256.Size]
+	}
+	return out
+}
+
+// keyManagementServer implements the encryption calls of the KMS API.
+type keyManagementServer struct {
+	kmspb.UnimplementedKeyManagementServiceServer
+	s *Server
+}
+
+func (k *keyManagementServer) Encrypt(ctx context.Context, req *kmspb.EncryptRequest) (*kmspb.EncryptResponse, error) {
+	ciphertext, err := k.s.Encrypt(req.GetName(), req.GetPlaintext())
+	if err != nil {
+		return nil, err
+	}
+	return &kmspb.EncryptResponse{
+		Name:       req.GetName() + "/cryptoKeyVersions/1",
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+func (k *keyManagementServer) Decrypt(ctx context.Context, req *kmspb.DecryptRequest) (*kmspb.DecryptResponse, error) {
+	plaintext, err := k.s.Decrypt(req.GetName(), req.GetCiphertext())
+	if err != nil {
+		return nil, err
+	}
+	return &kmspb.DecryptResponse{Plaintext: plaintext}, nil
+}
@@ -0,0 +1,198 @@
+package gcpenvtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type secret struct {
+	created  time.Time
+	versions []*secretVersion
+}
+
+type secretVersion struct {
+	created time.Time
+	state   secretmanagerpb.SecretVersion_State
+	payload []byte
+}
+
+// AddSecretVersion adds a version to the secret
+// (projects/{PROJECT}/secrets/{SECRET}), creating the secret if needed, and
+// returns the name of the version.
+func (s *Server) AddSecretVersion(name string, payload []byte) (string, error) {
+	if !secretName.MatchString(name) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid secret name: '%s'", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, ok := s.secrets[name]
+	if !ok {
+		sec = &secret{created: time.Now()}
+		s.secrets[name] = sec
+	}
+	return s.addVersion(name, sec, payload), nil
+}
+
+// DisableSecretVersion disables the secret version
+// (projects/{PROJECT}/secrets/{SECRET}/versions/{VERSION}).
+func (s *Server) DisableSecretVersion(name string) error {
+	_, err := s.setState(name, secretmanagerpb.SecretVersion_DISABLED)
+	return err
+}
+
+// EnableSecretVersion enables the secret version
+// (projects/{PROJECT}/secrets/{SECRET}/versions/{VERSION}).
+func (s *Server) EnableSecretVersion(name string) error {
+	_, err := s.setState(name, secretmanagerpb.SecretVersion_ENABLED)
+	return err
+}
+
+// addVersion must be called with s.mu held.
+func (s *Server) addVersion(name string, sec *secret, payload []byte) string {
+	sec.versions = append(sec.versions, &secretVersion{
+		created: time.Now(),
+		state:   secretmanagerpb.SecretVersion_ENABLED,
+		payload: append([]byte(nil), payload...),
+	})
+	return fmt.Sprintf("%s/versions/%d", name, len(sec.versions))
+}
+
+func (s *Server) setState(name string, state secretmanagerpb.SecretVersion_State) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name, v, err := s.version(name)
+	if err != nil {
+		return nil, err
+	}
+	v.state = state
+	return v.proto(name), nil
+}
+
+// version returns the secret version and its canonical name, resolving the
+// latest alias to the most recently created version. It must be called with
+// s.mu held.
+func (s *Server) version(name string) (string, *secretVersion, error) {
+	m := secretVersionName.FindStringSubmatch(name)
+	if m == nil {
+		return "", nil, status.Errorf(codes.InvalidArgument, "invalid secret version name: '%s'", name)
+	}
+	sec, ok := s.secrets[m[1]]
+	if !ok {
+		return "", nil, status.Errorf(codes.NotFound, "Secret [%s] not found or has no versions.", m[1])
+	}
+	n := len(sec.versions)
+	if m[2] != "latest" {
+		var err error
+		if n, err = strconv.Atoi(m[2]); err != nil || n < 1 {
+			return "", nil, status.Errorf(codes.InvalidArgument, "invalid secret version name: '%s'", name)
+		}
+		if n > len(sec.versions) {
+			return "", nil, status.Errorf(codes.NotFound, "Secret Version [%s] not found.", name)
+		}
+	}
+	if n == 0 {
+		return "", nil, status.Errorf(codes.NotFound, "Secret [%s] not found or has no versions.", m[1])
+	}
+	return fmt.Sprintf("%s/versions/%d", m[1], n), sec.versions[n-1], nil
+}
+
+func (v *secretVersion) proto(name string) *secretmanagerpb.SecretVersion {
+	return &secretmanagerpb.SecretVersion{
+		Name:       name,
+		CreateTime: timestamppb.New(v.created),
+		State:      v.state,
+	}
+}
+
+// secretManagerServer implements the Secret Manager API.
+type secretManagerServer struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+	s *Server
+}
+
+func (m *secretManagerServer) CreateSecret(ctx context.Context, req *secretmanagerpb.CreateSecretRequest) (*secretmanagerpb.Secret, error) {
+	name := req.GetParent() + "/secrets/" + req.GetSecretId()
+	if !secretName.MatchString(name) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid secret name: '%s'", name)
+	}
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+	if _, ok := m.s.secrets[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "Secret [%s] already exists.", name)
+	}
+	sec := &secret{created: time.Now()}
+	m.s.secrets[name] = sec
+	return sec.proto(name), nil
+}
+
+func (m *secretManagerServer) GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest) (*secretmanagerpb.Secret, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+	sec, ok := m.s.secrets[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Secret [%s] not found.", req.GetName())
+	}
+	return sec.proto(req.GetName()), nil
+}
+
+func (m *secretManagerServer) AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+	sec, ok := m.s.secrets[req.GetParent()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Secret [%s] not found.", req.GetParent())
+	}
+	name := m.s.addVersion(req.GetParent(), sec, req.GetPayload().GetData())
+	return sec.versions[len(sec.versions)-1].proto(name), nil
+}
+
+func (m *secretManagerServer) GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+	name, v, err := m.s.version(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return v.proto(name), nil
+}
+
+func (m *secretManagerServer) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+	name, v, err := m.s.version(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if v.state != secretmanagerpb.SecretVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "Secret Version [%s] is in %s state.", name, v.state)
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    name,
+		Payload: &secretmanagerpb.SecretPayload{Data: append([]byte(nil), v.payload...)},
+	}, nil
+}
+
+func (m *secretManagerServer) DisableSecretVersion(ctx context.Context, req *secretmanagerpb.DisableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return m.s.setState(req.GetName(), secretmanagerpb.SecretVersion_DISABLED)
+}
+
+func (m *secretManagerServer) EnableSecretVersion(ctx context.Context, req *secretmanagerpb.EnableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return m.s.setState(req.GetName(), secretmanagerpb.SecretVersion_ENABLED)
+}
+
+func (sec *secret) proto(name string) *secretmanagerpb.Secret {
+	return &secretmanagerpb.Secret{
+		Name:       name,
+		CreateTime: timestamppb.New(sec.created),
+		Replication: &secretmanagerpb.Replication{
+			Replication: &secretmanagerpb.Replication_Automatic_{Automatic: &secretmanagerpb.Replication_Automatic{}},
+		},
+	}
+}
@@ -0,0 +1,119 @@
+// Package gcpenvtest provides in-process Secret Manager and KMS servers, for
+// testing code using gcp-env without Google Cloud.
+//
+//	server, err := gcpenvtest.NewServer(gcpenvtest.FixtureFromMap(map[string]string{
+//		"projects/p/secrets/db-password": "s3cr3t",
+//	}))
+//	if err != nil { ... }
+//	defer server.Close()
+//	provider, err := secrets.NewClient(ctx, nil, server.ClientOptions()...)
+//
+// The servers implement the calls made by gcp-env, keeping the secrets in
+// memory. The KMS cipher is deterministic and not secure.
+package gcpenvtest
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sync"
+
+	"github.com/telia-oss/gcp-env/pkg/secrets"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+	"google.golang.org/grpc"
+)
+
+var (
+	secretName        = regexp.MustCompile(`^projects/[^/]+/secrets/[a-zA-Z0-9_-]{1,255}$`)
+	secretVersionName = regexp.MustCompile(`^(projects/[^/]+/secrets/[a-zA-Z0-9_-]{1,255})/versions/([^/]+)$`)
+	cryptoKeyName     = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+)
+
+// Server serves the Secret Manager and KMS APIs on a loopback address.
+type Server struct {
+	// Addr is the host:port of both APIs.
+	Addr string
+	// Project is the project of the fixture.
+	Project string
+
+	mu      sync.Mutex
+	secrets map[string]*secret
+	keys    map[string]bool
+
+	server *grpc.Server
+}
+
+// NewServer starts a server with the content of the fixture, which may be nil.
+func NewServer(fixture *Fixture) (*Server, error) {
+	if fixture == nil {
+		fixture = &Fixture{}
+	}
+	s := &Server{
+		Project: fixture.Project,
+		secrets: make(map[string]*secret),
+		keys:    make(map[string]bool),
+	}
+	for name, versions := range fixture.Secrets {
+		name, err := fixture.secretName(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			version, err := s.AddSecretVersion(name, []byte(v.Value))
+			if err != nil {
+				return nil, err
+			}
+			if v.Disabled {
+				if err := s.DisableSecretVersion(version); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for _, key := range fixture.Keys {
+		if err := s.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	s.Addr = lis.Addr().String()
+	s.server = grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(s.server, &secretManagerServer{s: s})
+	kmspb.RegisterKeyManagementServiceServer(s.server, &keyManagementServer{s: s})
+	go s.server.Serve(lis)
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.server.Stop()
+}
+
+// ClientOptions returns the options of secrets.NewClient for clients of the
+// server.
+func (s *Server) ClientOptions() []secrets.ClientOption {
+	return []secrets.ClientOption{
+		secrets.WithSecretManagerEndpoint(s.Addr),
+		secrets.WithKMSEndpoint(s.Addr),
+		secrets.WithInsecure(),
+	}
+}
+
+// Env returns the variables (NAME=VALUE) which point gcp-env at the server:
+// the endpoints, and the project of the fixture for short sm:// references.
+// gcp-env also needs the --insecure flag.
+func (s *Server) Env() []string {
+	env := []string{
+		"GCP_ENV_SM_ENDPOINT=" + s.Addr,
+		"GCP_ENV_KMS_ENDPOINT=" + s.Addr,
+	}
+	if s.Project != "" {
+		env = append(env, "GCP_ENV_PROJECT="+s.Project)
+	}
+	return env
+}
@@ -0,0 +1,15 @@
+// Package secrets resolves references to secrets in Google Secret Manager
+// (sm://) and Cloud KMS (kms://, gcp+kms://), and to any backend registered as
+// a Resolver.
+//
+// A Provider is created with NewClient, which builds the Google Cloud clients
+// from credentials, or with NewSecretsProvider from any implementation of
+// GoogleSecretsManagerAPI and GoogleKeyManagementAPI, such as the fakes in
+// the secretsfakes package:
+//
+//	provider := secrets.NewSecretsProvider(ctx, kmsClient, smClient)
+//	value, err := provider.ResolveSecretContext(ctx, "sm://projects/p/secrets/db")
+//
+// The exported API follows semantic versioning with the module: it only
+// changes in backwards compatible ways within a major version.
+package secrets
@@ -0,0 +1,138 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Errors returned (wrapped) when resolving or validating references. Use
+// errors.Is to test for them, and errors.As to get the *Error or *ParseError
+// holding the reference.
+var (
+	// ErrInvalidReference is matched by every *ParseError.
+	ErrInvalidReference = errors.New("invalid reference")
+	// ErrUnsupportedScheme is returned for references without a resolver.
+	ErrUnsupportedScheme = errors.New("unsupported scheme")
+	// ErrSecretNotFound is returned when the secret, secret version or crypto key does not exist.
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrSecretDisabled is returned when the secret version is disabled or destroyed.
+	ErrSecretDisabled = errors.New("secret version is not enabled")
+	// ErrPermissionDenied is returned when the credentials lack access to the secret or key.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrUnauthenticated is returned when the credentials are missing or invalid.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrUnavailable is returned for transient failures of the Google APIs.
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrMissingKey is returned for kms://{base64} references when KMS_KEY_ID is not set.
+	ErrMissingKey = errors.New("missing required keyID to decrypt")
+	// ErrMissingProject is returned for short references when no default project is found.
+	ErrMissingProject = errors.New("failed to determine default project")
+	// ErrInvalidPayload is returned when the payload can't be decoded or lacks the selected field.
+	ErrInvalidPayload = errors.New("invalid secret payload")
+)
+
+// Error is returned when a reference fails to resolve.
+type Error struct {
+	// Name of the environment variable holding the reference, when known.
+	Name string
+	// Reference that failed to resolve. The error message only holds its
+	// redacted form, see Redact.
+	Reference string
+	// Err is the cause.
+	Err error
+	op  string
+}
+
+func (e *Error) Error() string {
+	op := e.op
+	if op == "" {
+		op = "failed to resolve secret"
+	}
+	msg := fmt.Sprintf("%s: '%s': %s", op, Redact(e.Reference), e.Err)
+	if e.Name != "" {
+		msg = fmt.Sprintf("failed to resolve environment variable: '%s': %s", e.Name, msg)
+	}
+	return msg
+}
+
+// Unwrap returns the cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// kindError classifies err as one of the errors above, keeping its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string        { return e.err.Error() }
+func (e *kindError) Unwrap() error        { return e.err }
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// apiError wraps an error returned by the Secret Manager or KMS client with
+// message, and classifies it by its gRPC status code.
+func apiError(err error, message string) error {
+	wrapped := errors.Wrap(err, message)
+	s, ok := status.FromError(err)
+	if !ok {
+		return wrapped
+	}
+	var kind error
+	switch s.Code() {
+	case codes.NotFound:
+		kind = ErrSecretNotFound
+	case codes.FailedPrecondition:
+		kind = ErrSecretDisabled
+	case codes.PermissionDenied:
+		kind = ErrPermissionDenied
+	case codes.Unauthenticated:
+		kind = ErrUnauthenticated
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		kind = ErrUnavailable
+	default:
+		return wrapped
+	}
+	return &kindError{kind: kind, err: wrapped}
+}
+
+// MultiError aggregates the failures of a call resolving several references,
+// e.g. one *Error per reference. errors.Is and errors.As match any of them.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors occurred:\n\t* %s", len(e.Errors), strings.Join(msgs, "\n\t* "))
+}
+
+// Is reports whether any of the errors matches target.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target.
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
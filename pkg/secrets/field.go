@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// extractField returns the field selected by path from a JSON payload, e.g.
+// `.password`, `.db.users[0].name`. String values are returned as is, other
+// values are returned as JSON. An empty path returns the payload untouched.
+func extractField(payload, path string) (string, error) {
+	if path == "" {
+		return payload, nil
+	}
+	steps, err := parseFieldPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return "", errors.New("failed to select field: secret payload is not valid JSON")
+	}
+
+	current := doc
+	for i, step := range steps {
+		var ok bool
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if step.index < 0 {
+				current, ok = node[step.key]
+			}
+		case []interface{}:
+			if step.index >= 0 && step.index < len(node) {
+				current, ok = node[step.index], true
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("failed to select field: '%s' not found in secret payload", formatFieldPath(steps[:i+1]))
+		}
+	}
+
+	if str, ok := current.(string); ok {
+		return str, nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(current); err != nil {
+		return "", errors.Wrap(err, "failed to select field")
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// fieldStep is either an object key or (when index >= 0) an array index.
+type fieldStep struct {
+	key   string
+	index int
+}
+
+func parseFieldPath(path string) ([]fieldStep, error) {
+	var steps []fieldStep
+	rest := strings.TrimPrefix(path, ".")
+	for _, part := range strings.Split(rest, ".") {
+		key := part
+		var indexes []int
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key = part[:i]
+			for _, index := range strings.Split(strings.TrimSuffix(part[i+1:], "]"), "][") {
+				n, err := strconv.Atoi(index)
+				if err != nil || n < 0 || !strings.HasSuffix(part, "]") {
+					return nil, fmt.Errorf("invalid field: '%s'", path)
+				}
+				indexes = append(indexes, n)
+			}
+		}
+		if key == "" && len(indexes) == 0 {
+			return nil, fmt.Errorf("invalid field: '%s'", path)
+		}
+		if key != "" {
+			steps = append(steps, fieldStep{key: key, index: -1})
+		}
+		for _, n := range indexes {
+			steps = append(steps, fieldStep{index: n})
+		}
+	}
+	return steps, nil
+}
+
+func formatFieldPath(steps []fieldStep) string {
+	var b strings.Builder
+	for _, step := range steps {
+		if step.index >= 0 {
+			fmt.Fprintf(&b, "[%d]", step.index)
+		} else {
+			b.WriteString("." + step.key)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// modifiers are the options shared by the built-in resolvers, set with
+// ?{NAME}={VALUE} (and #.{FIELD} for the field):
+//
+//	field     select a field of a JSON payload
+//	optional  resolve to an empty value when the secret does not exist (sm only)
+//	default   resolve to this value when the secret does not exist (sm only)
+//	encoding  decode the payload: raw (default), base64 or hex
+//	trim      trim leading and trailing whitespace: true or false
+//
+// The payload is trimmed, decoded and then the field is selected.
+type modifiers struct {
+	field        string
+	optional     bool
+	hasDefault   bool
+	defaultValue string
+	encoding     string
+	trim         bool
+}
+
+const (
+	encodingRaw    = "raw"
+	encodingBase64 = "base64"
+	encodingHex    = "hex"
+)
+
+// parseModifiers parses the modifiers of a reference, trim sets the default
+// for the trim modifier.
+func parseModifiers(query map[string]string, trim bool) (modifiers, error) {
+	mods := modifiers{encoding: encodingRaw, trim: trim, field: query["field"]}
+
+	if optional, ok := query["optional"]; ok {
+		var err error
+		if mods.optional, err = strconv.ParseBool(optional); err != nil {
+			return mods, fmt.Errorf("invalid modifier: 'optional': expected true or false")
+		}
+	}
+	mods.defaultValue, mods.hasDefault = query["default"]
+
+	if trim, ok := query["trim"]; ok {
+		var err error
+		if mods.trim, err = strconv.ParseBool(trim); err != nil {
+			return mods, fmt.Errorf("invalid modifier: 'trim': expected true or false")
+		}
+	}
+	if encoding, ok := query["encoding"]; ok {
+		switch encoding {
+		case encodingRaw, encodingBase64, encodingHex:
+			mods.encoding = encoding
+		default:
+			return mods, fmt.Errorf("invalid modifier: 'encoding': expected %s, %s or %s", encodingRaw, encodingBase64, encodingHex)
+		}
+	}
+	return mods, nil
+}
+
+// apply post-processes the payload of a secret.
+func (m modifiers) apply(secret string) (string, error) {
+	if m.trim {
+		secret = strings.TrimSpace(secret)
+	}
+	switch m.encoding {
+	case encodingBase64:
+		data, err := decodeBase64(secret)
+		if err != nil {
+			return "", &kindError{kind: ErrInvalidPayload, err: fmt.Errorf("failed to decode base64 payload: %s", err)}
+		}
+		secret = string(data)
+	case encodingHex:
+		data, err := hex.DecodeString(secret)
+		if err != nil {
+			// the error would quote the invalid (secret) character
+			return "", &kindError{kind: ErrInvalidPayload, err: errors.New("failed to decode hex payload: invalid hex data")}
+		}
+		secret = string(data)
+	}
+	secret, err := extractField(secret, m.field)
+	if err != nil {
+		return "", &kindError{kind: ErrInvalidPayload, err: err}
+	}
+	return secret, nil
+}
+
+// fallback returns the value to use instead of failing with err, which is only
+// possible for secrets that do not exist (e.g. not permission errors).
+func (m modifiers) fallback(err error) (string, bool) {
+	if !(m.optional || m.hasDefault) || !isNotFound(err) {
+		return "", false
+	}
+	return m.defaultValue, true
+}
+
+// isNotFound reports whether err is (or wraps) ErrSecretNotFound or a gRPC
+// NotFound status.
+func isNotFound(err error) bool {
+	if errors.Is(err, ErrSecretNotFound) {
+		return true
+	}
+	for err != nil {
+		if s, ok := status.FromError(err); ok {
+			return s.Code() == codes.NotFound
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
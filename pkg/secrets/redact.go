@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// redacted replaces values which must not be shown.
+const redacted = "REDACTED"
+
+// safeModifiers are the modifiers which never hold secret material.
+var safeModifiers = map[string]bool{
+	"field":    true,
+	"optional": true,
+	"encoding": true,
+	"trim":     true,
+	"location": true,
+	"project":  true,
+}
+
+// Redact returns an identifier for the reference which is safe to show in
+// errors, logs and reports. Ciphertext is replaced by a short fingerprint,
+// and credentials ({USER}:{TOKEN}@) and other modifiers that may hold secret
+// material (like default values) are removed. Values which are not references
+// are redacted entirely.
+func Redact(ref string) string {
+	scheme, ok := schemeOf(ref)
+	if !ok {
+		return redacted
+	}
+	path, query, fragment, err := splitReference(strings.TrimPrefix(ref, scheme+"://"))
+	if err != nil {
+		return scheme + "://" + redacted
+	}
+	if scheme == kmsScheme && !strings.HasPrefix(path, "projects/") {
+		// legacy kms://{base64} reference
+		path = fingerprint(path)
+	}
+	if host := strings.SplitN(path, "/", 2)[0]; strings.Contains(host, "@") {
+		// credentials in {USER}:{TOKEN}@{HOST}/... of registered schemes
+		path = redacted + path[strings.LastIndex(host, "@"):]
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var modifiers []string
+	for _, key := range keys {
+		switch {
+		case key == "ciphertext":
+			modifiers = append(modifiers, key+"="+fingerprint(query[key]))
+		case safeModifiers[key]:
+			modifiers = append(modifiers, key+"="+query[key])
+		default:
+			modifiers = append(modifiers, key+"="+redacted)
+		}
+	}
+
+	safe := scheme + "://" + path
+	if len(modifiers) > 0 {
+		safe += "?" + strings.Join(modifiers, "&")
+	}
+	if fragment != "" {
+		safe += "#" + fragment
+	}
+	return safe
+}
+
+// fingerprint identifies ciphertext without revealing it.
+func fingerprint(ciphertext string) string {
+	sum := sha256.Sum256([]byte(ciphertext))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
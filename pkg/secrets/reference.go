@@ -0,0 +1,360 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Reference is a parsed secret reference. See ParseReference for the
+// supported formats.
+type Reference struct {
+	// Scheme is sm, kms, gcp+kms or the scheme of a registered resolver.
+	Scheme string
+	// Project of the secret or crypto key. It is empty for short references,
+	// which use the default project of the Provider.
+	Project string
+	// Secret and Version of sm:// references.
+	Secret  string
+	Version string
+	// Location, KeyRing and CryptoKey of kms:// and gcp+kms:// references. The
+	// key is empty for kms://{base64} references, which use KMS_KEY_ID.
+	Location  string
+	KeyRing   string
+	CryptoKey string
+	// Ciphertext (base64) of kms:// and gcp+kms:// references.
+	Ciphertext string
+	// Field selected from a JSON payload, e.g. ".password".
+	Field string
+	// Modifiers holds the remaining modifiers: optional, default, encoding and trim.
+	Modifiers map[string]string
+	// Path holds everything after the scheme of references handled by a
+	// registered resolver, which are not parsed any further.
+	Path string
+}
+
+// ParseError is returned for references with invalid syntax.
+type ParseError struct {
+	// Reference is the invalid reference.
+	Reference string
+	// Reason describes what is wrong with it.
+	Reason string
+	// Err is the cause, if any (e.g. ErrUnsupportedScheme).
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return "invalid reference: " + e.Reason
+}
+
+// Unwrap returns the cause.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes every *ParseError match ErrInvalidReference.
+func (e *ParseError) Is(target error) bool {
+	return target == ErrInvalidReference
+}
+
+var (
+	// validProject accepts project IDs (including domain-scoped ones) and numbers
+	validProject = regexp.MustCompile(`^[a-z0-9][a-z0-9.:-]{0,99}$`)
+	// validSecret is the naming rule of Secret Manager secrets
+	validSecret = regexp.MustCompile(`^[A-Za-z0-9_-]{1,255}$`)
+	// validVersion is either latest or a version number
+	validVersion = regexp.MustCompile(`^(latest|[1-9][0-9]*)$`)
+	// validLocation is a KMS location such as global or europe-north1
+	validLocation = regexp.MustCompile(`^[a-z0-9-]{1,63}$`)
+	// validKeyName is the naming rule of KMS key rings and crypto keys
+	validKeyName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+	// cryptoKeyName matches the resource name of a KMS crypto key
+	cryptoKeyName = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/keyRings/([^/]+)/cryptoKeys/([^/]+)$`)
+	// secretResourceName matches the resource name of a secret (optionally with version)
+	secretResourceName = regexp.MustCompile(`^projects/([^/]+)/secrets/([^/]+)(?:/versions/([^/]+))?$`)
+)
+
+// modifiersByScheme lists the modifiers accepted by the built-in schemes.
+var modifiersByScheme = map[string]map[string]bool{
+	smScheme:     {"field": true, "optional": true, "default": true, "encoding": true, "trim": true},
+	kmsScheme:    {"field": true, "optional": true, "default": true, "encoding": true, "trim": true, "ciphertext": true},
+	gcpKMSScheme: {"field": true, "optional": true, "default": true, "encoding": true, "trim": true, "ciphertext": true, "project": true, "location": true},
+}
+
+// ParseReference parses and validates a reference to a secret in one of the
+// formats of the built-in resolvers:
+//
+//	sm://projects/{PROJECT_ID}/secrets/{SECRET_NAME}[/versions/{VERSION|latest}]
+//	sm://{SECRET_NAME}[#{VERSION|latest}]
+//	kms://projects/{PROJECT_ID}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}?ciphertext={base64}
+//	gcp+kms://{KEYRING}/{KEY}?ciphertext={base64}[&location={LOCATION}][&project={PROJECT_ID}]
+//	kms://{base64}
+//
+// followed by optional modifiers (?optional=true&default=...&encoding=...&trim=...)
+// and a JSON field (#.{FIELD} or ?field={FIELD}). Errors are of type *ParseError.
+func ParseReference(value string) (*Reference, error) {
+	scheme, ok := schemeOf(value)
+	if !ok {
+		return nil, &ParseError{Reference: value, Reason: "missing scheme"}
+	}
+	allowed, ok := modifiersByScheme[scheme]
+	if !ok {
+		return nil, &ParseError{Reference: value, Reason: fmt.Sprintf("unsupported scheme: '%s'", scheme), Err: ErrUnsupportedScheme}
+	}
+	path, query, fragment, err := splitReference(strings.TrimPrefix(value, scheme+"://"))
+	if err != nil {
+		return nil, &ParseError{Reference: value, Reason: err.Error()}
+	}
+	fail := func(format string, args ...interface{}) (*Reference, error) {
+		return nil, &ParseError{Reference: value, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	ref := &Reference{Scheme: scheme, Modifiers: make(map[string]string)}
+	for key, v := range query {
+		switch {
+		case !allowed[key]:
+			return fail("unsupported modifier: '%s'", key)
+		case key == "field" && v != "" && !strings.HasPrefix(v, "."):
+			// ?field=password selects the same field as #.password
+			ref.Field = "." + v
+		case key == "field":
+			ref.Field = v
+		case key == "ciphertext":
+			ref.Ciphertext = v
+		case key == "project":
+			ref.Project = v
+		case key == "location":
+			ref.Location = v
+		default:
+			ref.Modifiers[key] = v
+		}
+	}
+
+	switch scheme {
+	case smScheme:
+		// the fragment holds the version, the field or both: #{VERSION}.{FIELD}
+		version := fragment
+		if i := strings.IndexByte(fragment, '.'); i >= 0 {
+			version, fragment = fragment[:i], fragment[i:]
+		} else {
+			fragment = ""
+		}
+		if m := secretResourceName.FindStringSubmatch(path); m != nil {
+			ref.Project, ref.Secret, ref.Version = m[1], m[2], m[3]
+			if version != "" && ref.Version != "" {
+				return fail("version set in both name and fragment")
+			}
+		} else if strings.Contains(path, "/") {
+			return fail("expected projects/{PROJECT_ID}/secrets/{SECRET_NAME}[/versions/{VERSION}] or {SECRET_NAME}")
+		} else {
+			ref.Secret = path
+		}
+		if version != "" {
+			ref.Version = version
+		}
+		if ref.Version == "" {
+			ref.Version = "latest"
+		}
+		if ref.Project != "" && !validProject.MatchString(ref.Project) {
+			return fail("invalid project: '%s'", ref.Project)
+		}
+		if !validSecret.MatchString(ref.Secret) {
+			return fail("invalid secret name: '%s'", ref.Secret)
+		}
+		if !validVersion.MatchString(ref.Version) {
+			return fail("invalid secret version: '%s'", ref.Version)
+		}
+	case kmsScheme, gcpKMSScheme:
+		switch {
+		case scheme == gcpKMSScheme:
+			parts := strings.Split(path, "/")
+			if len(parts) != 2 {
+				return fail("expected %s{KEYRING}/{KEY}", gcpKMSPrefix)
+			}
+			ref.KeyRing, ref.CryptoKey = parts[0], parts[1]
+			if ref.Location == "" {
+				ref.Location = defaultKMSLocation
+			}
+		case strings.HasPrefix(path, "projects/"):
+			m := cryptoKeyName.FindStringSubmatch(path)
+			if m == nil {
+				return fail("expected projects/{PROJECT}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}")
+			}
+			ref.Project, ref.Location, ref.KeyRing, ref.CryptoKey = m[1], m[2], m[3], m[4]
+		default:
+			// legacy kms://{base64} reference using the key in KMS_KEY_ID
+			if ref.Ciphertext != "" {
+				return fail("ciphertext set in both path and modifier")
+			}
+			ref.Ciphertext = path
+		}
+		if ref.CryptoKey != "" {
+			if ref.Project != "" && !validProject.MatchString(ref.Project) {
+				return fail("invalid project: '%s'", ref.Project)
+			}
+			if !validLocation.MatchString(ref.Location) {
+				return fail("invalid location: '%s'", ref.Location)
+			}
+			if !validKeyName.MatchString(ref.KeyRing) {
+				return fail("invalid key ring: '%s'", ref.KeyRing)
+			}
+			if !validKeyName.MatchString(ref.CryptoKey) {
+				return fail("invalid crypto key: '%s'", ref.CryptoKey)
+			}
+		}
+		if ref.Ciphertext == "" {
+			return fail("missing ciphertext")
+		}
+		if _, err := decodeBase64(ref.Ciphertext); err != nil {
+			return fail("ciphertext is not valid base64")
+		}
+	}
+
+	if fragment != "" {
+		if ref.Field != "" {
+			return fail("field selected by both ?field= and fragment")
+		}
+		if !strings.HasPrefix(fragment, ".") {
+			return fail("invalid field: '%s': must start with '.'", fragment)
+		}
+		ref.Field = fragment
+	}
+	if _, err := ref.modifiers(false); err != nil {
+		return fail("%s", err)
+	}
+	return ref, nil
+}
+
+// String returns the canonical form of the reference. Short references and
+// gcp+kms:// references without a project keep their short form.
+func (r *Reference) String() string {
+	var b strings.Builder
+	var modifiers []string
+	var fragment string
+	switch {
+	case r.Path != "":
+		return r.Scheme + "://" + r.Path
+	case r.Scheme == smScheme && r.Project != "":
+		fmt.Fprintf(&b, "sm://projects/%s/secrets/%s/versions/%s", r.Project, r.Secret, r.Version)
+		fragment = r.Field
+	case r.Scheme == smScheme:
+		fmt.Fprintf(&b, "sm://%s", r.Secret)
+		fragment = r.Version + r.Field
+	case r.CryptoKey == "":
+		b.WriteString("kms://" + r.Ciphertext)
+	case r.Project != "":
+		fmt.Fprintf(&b, "kms://projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s", r.Project, r.Location, r.KeyRing, r.CryptoKey)
+		modifiers = append(modifiers, "ciphertext="+r.Ciphertext)
+	default:
+		fmt.Fprintf(&b, "gcp+kms://%s/%s", r.KeyRing, r.CryptoKey)
+		modifiers = append(modifiers, "ciphertext="+r.Ciphertext)
+		if r.Location != defaultKMSLocation {
+			modifiers = append(modifiers, "location="+escapeModifier(r.Location))
+		}
+	}
+
+	keys := make([]string, 0, len(r.Modifiers))
+	for key := range r.Modifiers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		modifiers = append(modifiers, key+"="+escapeModifier(r.Modifiers[key]))
+	}
+	if r.Scheme != smScheme && r.Field != "" {
+		modifiers = append(modifiers, "field="+escapeModifier(r.Field))
+	}
+
+	if len(modifiers) > 0 {
+		b.WriteString("?" + strings.Join(modifiers, "&"))
+	}
+	if fragment != "" {
+		b.WriteString("#" + fragment)
+	}
+	return b.String()
+}
+
+// modifiers returns the options of the reference, trim sets the default for
+// the trim modifier.
+func (r *Reference) modifiers(trim bool) (modifiers, error) {
+	query := map[string]string{"field": r.Field}
+	for key, value := range r.Modifiers {
+		query[key] = value
+	}
+	return parseModifiers(query, trim)
+}
+
+// ParseReference parses value like the package level ParseReference, but also
+// accepts the schemes of registered resolvers. Their references are not parsed
+// any further than the scheme.
+func (s *Provider) ParseReference(value string) (*Reference, error) {
+	scheme, ok := schemeOf(value)
+	if !ok {
+		return nil, &ParseError{Reference: value, Reason: "missing scheme"}
+	}
+	if s.Resolvers != nil {
+		if _, ok := s.Resolvers.Lookup(scheme); ok {
+			return &Reference{Scheme: scheme, Path: strings.TrimPrefix(value, scheme+"://")}, nil
+		}
+	}
+	return ParseReference(value)
+}
+
+// schemeOf returns the scheme of a reference such as sm://..., if any.
+func schemeOf(value string) (string, bool) {
+	i := strings.Index(value, "://")
+	if i < 1 {
+		return "", false
+	}
+	for _, c := range value[:i] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.') {
+			return "", false
+		}
+	}
+	return value[:i], true
+}
+
+// splitReference splits a reference (without the scheme) into its path,
+// modifiers and fragment: {PATH}?{MODIFIERS}#{FRAGMENT}.
+func splitReference(ref string) (string, map[string]string, string, error) {
+	var rawQuery, fragment string
+	if i := strings.IndexByte(ref, '#'); i >= 0 {
+		ref, fragment = ref[:i], ref[i+1:]
+	}
+	if i := strings.IndexByte(ref, '?'); i >= 0 {
+		ref, rawQuery = ref[:i], ref[i+1:]
+	}
+	query, err := parseQuery(rawQuery)
+	if err != nil {
+		return "", nil, "", err
+	}
+	return ref, query, fragment, nil
+}
+
+// parseQuery parses the modifiers of a reference. Unlike url.ParseQuery a '+'
+// is kept as is, since it is commonly found in base64 encoded ciphertext.
+func parseQuery(rawQuery string) (map[string]string, error) {
+	query := make(map[string]string)
+	if rawQuery == "" {
+		return query, nil
+	}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		kv := strings.SplitN(pair, "=", 2)
+		// the errors leave out the values, which may hold secret material
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.New("invalid modifier: expected {NAME}={VALUE}")
+		}
+		value, err := url.PathUnescape(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid modifier: '%s': invalid escape sequence", kv[0])
+		}
+		query[kv[0]] = value
+	}
+	return query, nil
+}
+
+// escapeModifier escapes the characters which would end a modifier value.
+var escapeModifier = strings.NewReplacer("%", "%25", "&", "%26", "#", "%23").Replace
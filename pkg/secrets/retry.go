@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls how failed calls to Secret Manager and KMS are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts per call, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// Initial is the pause before the first retry. It grows by Multiplier after
+	// every retry, up to Max.
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter is the fraction (0 to 1) of each pause which is randomised, to
+	// avoid many clients retrying in lockstep.
+	Jitter float64
+	// Codes are the gRPC status codes which are retried.
+	Codes []codes.Code
+}
+
+// DefaultRetryPolicy is used when Provider.Retry is not set.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Initial:     100 * time.Millisecond,
+	Max:         5 * time.Second,
+	Multiplier:  2,
+	Jitter:      1,
+	Codes:       []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded},
+}
+
+// CallOptions returns the options applying the policy to an API call. They
+// replace the default retry settings of the Google Cloud clients.
+func (p RetryPolicy) CallOptions() []gax.CallOption {
+	return []gax.CallOption{gax.WithRetry(func() gax.Retryer {
+		if p.MaxAttempts < 2 {
+			return nil
+		}
+		return &retryer{policy: p}
+	})}
+}
+
+// retryer implements gax.Retryer for a single call.
+type retryer struct {
+	policy   RetryPolicy
+	attempts int
+	pause    time.Duration
+}
+
+func (r *retryer) Retry(err error) (time.Duration, bool) {
+	r.attempts++
+	if r.attempts >= r.policy.MaxAttempts || !r.retryable(err) {
+		return 0, false
+	}
+	if r.pause == 0 {
+		r.pause = r.policy.Initial
+	} else if r.policy.Multiplier > 1 {
+		r.pause = time.Duration(float64(r.pause) * r.policy.Multiplier)
+	}
+	if r.policy.Max > 0 && r.pause > r.policy.Max {
+		r.pause = r.policy.Max
+	}
+	pause := r.pause
+	if r.policy.Jitter > 0 {
+		pause -= time.Duration(r.policy.Jitter * rand.Float64() * float64(pause))
+	}
+	return pause, true
+}
+
+func (r *retryer) retryable(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	for _, code := range r.policy.Codes {
+		if s.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// callOptions returns the options of every API call made by the provider.
+func (s *Provider) callOptions() []gax.CallOption {
+	policy := DefaultRetryPolicy
+	if s.Retry != nil {
+		policy = *s.Retry
+	}
+	return policy.CallOptions()
+}
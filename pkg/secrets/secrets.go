@@ -0,0 +1,684 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/compute/metadata"
+	kms "cloud.google.com/go/kms/apiv1"
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+
+	"github.com/googleapis/gax-go/v2"
+	"github.com/pkg/errors"
+	"github.com/telia-oss/gcp-env/pkg/credentials"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+const (
+	// The secret should be in one of the formats
+	// kms://{base64} (decrypted with the key in KMS_KEY_ID)
+	// kms://projects/{PROJECT_ID}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}?ciphertext={base64}
+	// gcp+kms://{KEYRING}/{KEY}?ciphertext={base64}[&location={LOCATION}][&project={PROJECT_ID}]
+	gcpKMSPrefix = gcpKMSScheme + "://"
+
+	// kmsKeyEnv holds the key used for kms://{base64} references
+	kmsKeyEnv = "KMS_KEY_ID"
+	// defaultKMSLocation is used by gcp+kms:// references without a location
+	defaultKMSLocation = "global"
+
+	// The secret name should be in the format (optionally with version)
+	// `sm://projects/{PROJECT_ID}/secrets/{SECRET_NAME}`
+	// `sm://projects/{PROJECT_ID}/secrets/{SECRET_NAME}/versions/{VERSION|latest}`
+	// or the short form, using the default project
+	// `sm://{SECRET_NAME}`
+	// `sm://{SECRET_NAME}#{VERSION|latest}`
+	smPrefix = smScheme + "://"
+
+	// projectEnv overrides the default project used by short sm:// references
+	projectEnv = "GCP_ENV_PROJECT"
+
+	// smEndpointEnv and kmsEndpointEnv override the endpoints of the API
+	// clients created by NewClient, e.g. to use an emulator.
+	smEndpointEnv  = "GCP_ENV_SM_ENDPOINT"
+	kmsEndpointEnv = "GCP_ENV_KMS_ENDPOINT"
+
+	kmsScheme    = "kms"
+	gcpKMSScheme = "gcp+kms"
+	smScheme     = "sm"
+
+	// DefaultConcurrency is the number of secrets resolved in parallel when
+	// Provider.Concurrency is not set.
+	DefaultConcurrency = 10
+)
+
+// Resolver resolves secret references for a single URI scheme. The reference
+// is passed in full, including the scheme prefix.
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// ResolverFunc is an adapter to allow the use of ordinary functions as a Resolver.
+type ResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve calls f(ctx, ref).
+func (f ResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// Registry maps URI schemes (e.g. "sm" for sm://) to resolvers.
+type Registry struct {
+	mu        sync.RWMutex
+	resolvers map[string]Resolver
+}
+
+// NewRegistry creates an empty resolver registry.
+func NewRegistry() *Registry {
+	return &Registry{resolvers: make(map[string]Resolver)}
+}
+
+// Register adds a resolver for the scheme, replacing any previous registration.
+func (r *Registry) Register(scheme string, resolver Resolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolvers[scheme] = resolver
+}
+
+// Lookup returns the resolver registered for the scheme.
+func (r *Registry) Lookup(scheme string) (Resolver, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	resolver, ok := r.resolvers[scheme]
+	return resolver, ok
+}
+
+// Provider Google Cloud API provider
+type Provider struct {
+	KMSClient GoogleKeyManagementAPI
+	SMClient  GoogleSecretsManagerAPI
+	// Resolvers holds additional resolvers. Schemes registered here take
+	// precedence over the built-in kms://, gcp+kms:// and sm:// resolvers.
+	// A Registry may be shared by several Providers.
+	Resolvers *Registry
+	// Concurrency limits the number of references resolved in parallel.
+	// Defaults to DefaultConcurrency.
+	Concurrency int
+	// ProjectID is the default project for short sm://{SECRET_NAME} references.
+	// When empty, GCP_ENV_PROJECT and then the GCE metadata server are used.
+	ProjectID string
+	// Retry is applied to every call to Secret Manager and KMS. Defaults to
+	// DefaultRetryPolicy.
+	Retry *RetryPolicy
+}
+
+// ClientOption configures NewClient.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	impersonate *credentials.ImpersonateOptions
+	smEndpoint  string
+	kmsEndpoint string
+	insecure    bool
+}
+
+// WithImpersonation makes the clients impersonate a service account, using
+// tokens generated with the credentials passed to NewClient.
+func WithImpersonation(opts credentials.ImpersonateOptions) ClientOption {
+	return func(o *clientOptions) {
+		o.impersonate = &opts
+	}
+}
+
+// WithSecretManagerEndpoint sets the Secret Manager endpoint (host:port),
+// e.g. a regional or Private Service Connect endpoint. It overrides
+// GCP_ENV_SM_ENDPOINT.
+func WithSecretManagerEndpoint(endpoint string) ClientOption {
+	return func(o *clientOptions) {
+		o.smEndpoint = endpoint
+	}
+}
+
+// WithKMSEndpoint sets the KMS endpoint (host:port). It overrides
+// GCP_ENV_KMS_ENDPOINT.
+func WithKMSEndpoint(endpoint string) ClientOption {
+	return func(o *clientOptions) {
+		o.kmsEndpoint = endpoint
+	}
+}
+
+// WithInsecure disables authentication and TLS, for local emulators only.
+// The credentials passed to NewClient are ignored and may be nil.
+func WithInsecure() ClientOption {
+	return func(o *clientOptions) {
+		o.insecure = true
+	}
+}
+
+// NewClient is a global exported function that creates a new client
+func NewClient(ctx context.Context, creds *google.Credentials, opts ...ClientOption) (*Provider, error) {
+	o := clientOptions{
+		smEndpoint:  os.Getenv(smEndpointEnv),
+		kmsEndpoint: os.Getenv(kmsEndpointEnv),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.insecure {
+		if o.impersonate != nil {
+			return nil, errors.New("impersonation requires authentication, but the clients are insecure")
+		}
+		creds = nil
+	}
+	if o.impersonate != nil {
+		var err error
+		if creds, err = credentials.Impersonate(ctx, creds, *o.impersonate); err != nil {
+			return nil, err
+		}
+	}
+
+	kmsClient, err := kms.NewKeyManagementClient(ctx, o.apiOptions(creds, o.kmsEndpoint)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Google Cloud KMS SDK")
+	}
+
+	smClient, err := secretmanager.NewClient(ctx, o.apiOptions(creds, o.smEndpoint)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Google Cloud Secret Manager SDK")
+	}
+	client := NewSecretsProvider(ctx, kmsClient, smClient)
+	client.ProjectID = os.Getenv(projectEnv)
+	if client.ProjectID == "" && creds != nil {
+		client.ProjectID = creds.ProjectID
+	}
+	return client, nil
+}
+
+// apiOptions returns the options of an API client using endpoint, or the
+// default endpoint when it is empty.
+func (o clientOptions) apiOptions(creds *google.Credentials, endpoint string) []option.ClientOption {
+	var opts []option.ClientOption
+	if o.insecure {
+		opts = append(opts, option.WithoutAuthentication(), option.WithGRPCDialOption(grpc.WithInsecure()))
+	} else {
+		opts = append(opts, option.WithCredentials(creds))
+	}
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	return opts
+}
+
+// NewSecretsProvider is a global exported function that creates a new client.
+// ctx is not used: the context is passed to each call instead, e.g. to
+// ResolveSecretContext. It is kept for compatibility.
+func NewSecretsProvider(ctx context.Context, kmsClient GoogleKeyManagementAPI, smClient GoogleSecretsManagerAPI) *Provider {
+	return &Provider{
+		KMSClient: kmsClient,
+		SMClient:  smClient,
+		Resolvers: NewRegistry(),
+	}
+}
+
+// Register adds a resolver for references using the given scheme, replacing
+// any previous one, including the built-in resolvers. It creates
+// s.Resolvers when it is nil, so it must not be called concurrently with
+// the use of such a Provider.
+func (s *Provider) Register(scheme string, resolver Resolver) {
+	if s.Resolvers == nil {
+		s.Resolvers = NewRegistry()
+	}
+	s.Resolvers.Register(scheme, resolver)
+}
+
+// Supports reports whether value is a reference with a registered scheme.
+func (s *Provider) Supports(value string) bool {
+	_, ok := s.resolver(value)
+	return ok
+}
+
+func (s *Provider) resolver(value string) (Resolver, bool) {
+	scheme, ok := schemeOf(value)
+	if !ok {
+		return nil, false
+	}
+	return s.lookup(scheme)
+}
+
+// lookup returns the resolver registered for the scheme, or else the built-in
+// resolver bound to s.
+func (s *Provider) lookup(scheme string) (Resolver, bool) {
+	if s.Resolvers != nil {
+		if resolver, ok := s.Resolvers.Lookup(scheme); ok {
+			return resolver, true
+		}
+	}
+	switch scheme {
+	case kmsScheme, gcpKMSScheme:
+		return kmsResolver{s: s}, true
+	case smScheme:
+		return smResolver{s: s}, true
+	}
+	return nil, false
+}
+
+// ResolveSecret provides and interface to resolve a secret
+func (s *Provider) ResolveSecret(value string) (string, error) {
+	return s.ResolveSecretContext(context.Background(), value)
+}
+
+// ResolveSecretContext is like ResolveSecret, using ctx for the API calls.
+func (s *Provider) ResolveSecretContext(ctx context.Context, value string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", &Error{Reference: value, Err: err}
+	}
+	resolver, ok := s.resolver(value)
+	if !ok {
+		return "", &Error{Reference: value, Err: ErrUnsupportedScheme, op: "failed to fetch unsupported secret"}
+	}
+	secret, err := resolver.Resolve(ctx, value)
+	if err != nil {
+		// errors of registered resolvers are wrapped to carry the reference,
+		// including those of other references they resolved
+		var secretErr *Error
+		if !errors.As(err, &secretErr) || secretErr.Reference != value {
+			err = &Error{Reference: value, Err: err}
+		}
+		return "", err
+	}
+	return secret, nil
+}
+
+// Validator is implemented by resolvers which can check that a reference
+// resolves without fetching the secret itself.
+type Validator interface {
+	Validate(ctx context.Context, ref string) error
+}
+
+// ValidateSecret checks that value resolves, without returning the secret.
+// Resolvers that do not implement Validator resolve the secret and discard it.
+func (s *Provider) ValidateSecret(value string) error {
+	return s.ValidateSecretContext(context.Background(), value)
+}
+
+// ValidateSecretContext is like ValidateSecret, using ctx for the API calls.
+func (s *Provider) ValidateSecretContext(ctx context.Context, value string) error {
+	resolver, ok := s.resolver(value)
+	if !ok {
+		return &Error{Reference: value, Err: ErrUnsupportedScheme, op: "failed to validate unsupported secret"}
+	}
+	if validator, ok := resolver.(Validator); ok {
+		return validator.Validate(ctx, value)
+	}
+	_, err := resolver.Resolve(ctx, value)
+	return err
+}
+
+// kmsResolver is the built-in resolver for kms:// and gcp+kms:// references.
+type kmsResolver struct {
+	s *Provider
+}
+
+func (r kmsResolver) Resolve(ctx context.Context, value string) (string, error) {
+	keyName, ref, mods, err := r.s.parseKMS(value)
+	if err != nil {
+		return "", &Error{Reference: value, Err: err, op: "failed to decrypt kms secret"}
+	}
+	// optional and default don't apply: the ciphertext is part of the
+	// reference, so a NotFound error means that the key is missing
+	secret, err := r.s.decrypt(ctx, keyName, ref.Ciphertext)
+	if err != nil {
+		return "", &Error{Reference: value, Err: err, op: "failed to decrypt kms secret"}
+	}
+	if secret, err = mods.apply(secret); err != nil {
+		return "", &Error{Reference: value, Err: err, op: "failed to decrypt kms secret"}
+	}
+	return secret, nil
+}
+
+// Validate decrypts the secret (and applies the modifiers) without returning it.
+func (r kmsResolver) Validate(ctx context.Context, value string) error {
+	keyName, ref, mods, err := r.s.parseKMS(value)
+	if err != nil {
+		return err
+	}
+	secret, err := r.s.decrypt(ctx, keyName, ref.Ciphertext)
+	if err != nil {
+		return err
+	}
+	_, err = mods.apply(secret)
+	return err
+}
+
+func (s *Provider) parseKMS(value string) (string, *Reference, modifiers, error) {
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", nil, modifiers{}, err
+	}
+	// kms plaintext is trimmed by default for backwards compatibility
+	mods, err := ref.modifiers(true)
+	if err != nil {
+		return "", nil, modifiers{}, err
+	}
+	keyName, err := s.cryptoKey(ref)
+	if err != nil {
+		return "", nil, modifiers{}, err
+	}
+	return keyName, ref, mods, nil
+}
+
+// smResolver is the built-in resolver for sm:// references.
+type smResolver struct {
+	s *Provider
+}
+
+func (r smResolver) Resolve(ctx context.Context, value string) (string, error) {
+	name, mods, err := r.s.parseSM(value)
+	if err != nil {
+		return "", &Error{Reference: value, Err: err, op: "failed to fetch sm secret"}
+	}
+	secret, err := r.s.getSecretValue(ctx, name)
+	if err != nil {
+		if fallback, ok := mods.fallback(err); ok {
+			return fallback, nil
+		}
+		return "", &Error{Reference: value, Err: err, op: "failed to fetch sm secret"}
+	}
+	if secret, err = mods.apply(secret); err != nil {
+		return "", &Error{Reference: value, Err: err, op: "failed to fetch sm secret"}
+	}
+	return secret, nil
+}
+
+// Validate checks that the secret and version exist and that the version is
+// enabled, without accessing the payload.
+func (r smResolver) Validate(ctx context.Context, value string) error {
+	name, mods, err := r.s.parseSM(value)
+	if err != nil {
+		return err
+	}
+	if err := r.s.checkSecretVersion(ctx, name); err != nil {
+		if _, ok := mods.fallback(err); ok {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Provider) parseSM(value string) (string, modifiers, error) {
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", modifiers{}, err
+	}
+	mods, err := ref.modifiers(false)
+	if err != nil {
+		return "", modifiers{}, err
+	}
+	name, err := s.secretVersion(ref)
+	if err != nil {
+		return "", modifiers{}, err
+	}
+	return name, mods, nil
+}
+
+// ResolveSecrets provides and interface to resolve a list of secrets. Distinct
+// references are resolved concurrently (see Provider.Concurrency) and only
+// once, and the result preserves the order of values. Failures are returned
+// together as a *MultiError holding an *Error per reference.
+//
+// Values without a scheme, e.g. "hello", are returned unchanged and are not
+// an error, unlike with ResolveSecret, which fails with ErrUnsupportedScheme.
+// Values with a scheme that has no resolver still fail with
+// ErrUnsupportedScheme.
+func (s *Provider) ResolveSecrets(values []string) ([]string, error) {
+	return s.ResolveSecretsContext(context.Background(), values)
+}
+
+// ResolveSecretsContext is like ResolveSecrets, using ctx for the API calls.
+func (s *Provider) ResolveSecretsContext(ctx context.Context, values []string) ([]string, error) {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		// plain values are passed through untouched
+		if _, ok := schemeOf(v); !ok || seen[v] {
+			continue
+		}
+		seen[v] = true
+		refs = append(refs, v)
+	}
+	results := s.resolveAll(ctx, refs)
+
+	var secretlist []string
+	for _, v := range values {
+		r, ok := results[v]
+		if !ok {
+			secretlist = append(secretlist, v)
+			continue
+		}
+		secretlist = append(secretlist, r.value)
+	}
+	var errs []error
+	for _, ref := range refs {
+		if err := results[ref].err; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return secretlist, &MultiError{Errors: errs}
+	}
+	return secretlist, nil
+}
+
+type result struct {
+	value string
+	err   error
+}
+
+// resolveAll resolves each of refs using a bounded pool of workers.
+func (s *Provider) resolveAll(ctx context.Context, refs []string) map[string]result {
+	results := make(map[string]result, len(refs))
+	workers := s.Concurrency
+	if workers < 1 {
+		workers = DefaultConcurrency
+	}
+	if workers > len(refs) {
+		workers = len(refs)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range jobs {
+				value, err := s.ResolveSecretContext(ctx, ref)
+				mu.Lock()
+				results[ref] = result{value: value, err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, ref := range refs {
+		jobs <- ref
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (s *Provider) getSecretValue(ctx context.Context, name string) (string, error) {
+	// get secret value
+	accessReq := &secretmanagerpb.AccessSecretVersionRequest{
+		Name: name,
+	}
+
+	secret, err := s.SMClient.AccessSecretVersion(ctx, accessReq, s.callOptions()...)
+	if err != nil {
+		return "", apiError(err, "failed to access secret from Google Secret Manager")
+	}
+	return string(secret.Payload.GetData()), nil
+}
+
+// checkSecretVersion checks that the secret and secret version exist, and
+// that the version is enabled.
+func (s *Provider) checkSecretVersion(ctx context.Context, name string) error {
+	secretName := name[:strings.Index(name, "/versions/")]
+	if _, err := s.SMClient.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: secretName}, s.callOptions()...); err != nil {
+		return apiError(err, "failed to get secret from Google Secret Manager")
+	}
+	version, err := s.SMClient.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{Name: name}, s.callOptions()...)
+	if err != nil {
+		return apiError(err, "failed to get secret version from Google Secret Manager")
+	}
+	if state := version.GetState(); state != secretmanagerpb.SecretVersion_ENABLED {
+		return fmt.Errorf("%w: %s", ErrSecretDisabled, state)
+	}
+	return nil
+}
+
+// secretVersion returns the resource name of the secret version of an sm://
+// reference, using the default project for short references.
+func (s *Provider) secretVersion(ref *Reference) (string, error) {
+	project := ref.Project
+	if project == "" {
+		var err error
+		if project, err = s.projectID(); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("projects/%s/secrets/%s/versions/%s", project, ref.Secret, ref.Version), nil
+}
+
+// projectID returns the default project for short references.
+func (s *Provider) projectID() (string, error) {
+	if s.ProjectID != "" {
+		return s.ProjectID, nil
+	}
+	if project := os.Getenv(projectEnv); project != "" {
+		return project, nil
+	}
+	metadataProject.once.Do(func() {
+		if !metadata.OnGCE() {
+			metadataProject.err = fmt.Errorf("not running on GCE and %s is not set", projectEnv)
+			return
+		}
+		metadataProject.id, metadataProject.err = metadata.ProjectID()
+	})
+	if metadataProject.err != nil {
+		return "", fmt.Errorf("%w: %s", ErrMissingProject, metadataProject.err)
+	}
+	return metadataProject.id, nil
+}
+
+// metadataProject caches the project looked up from the GCE metadata server.
+var metadataProject struct {
+	once sync.Once
+	id   string
+	err  error
+}
+
+// cryptoKey returns the crypto key name of a kms:// or gcp+kms:// reference.
+func (s *Provider) cryptoKey(ref *Reference) (string, error) {
+	if ref.CryptoKey == "" {
+		// legacy kms://{base64} reference using the global key
+		keyName := os.Getenv(kmsKeyEnv)
+		if len(keyName) < 1 {
+			return "", fmt.Errorf("%w: %s is not set", ErrMissingKey, kmsKeyEnv)
+		}
+		return keyName, nil
+	}
+	project := ref.Project
+	if project == "" {
+		var err error
+		if project, err = s.projectID(); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s", project, ref.Location, ref.KeyRing, ref.CryptoKey), nil
+}
+
+func (s *Provider) decrypt(ctx context.Context, keyName, ciphertext string) (string, error) {
+	data, err := decodeBase64(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64 cipher: %s", err)
+	}
+	// decrypt secret value
+	req := &kmspb.DecryptRequest{
+		Name:       keyName,
+		Ciphertext: data,
+	}
+	resp, err := s.KMSClient.Decrypt(ctx, req, s.callOptions()...)
+	if err != nil {
+		return "", apiError(err, "failed to decrypt with Google Cloud KMS")
+	}
+	return string(resp.Plaintext), nil
+}
+
+// Encrypt encrypts plaintext with the KMS crypto key and returns a reference
+// which resolves to the plaintext:
+// kms://projects/{PROJECT_ID}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}?ciphertext={base64}
+func (s *Provider) Encrypt(keyName string, plaintext []byte) (string, error) {
+	return s.EncryptContext(context.Background(), keyName, plaintext)
+}
+
+// EncryptContext is like Encrypt, using ctx for the API call.
+func (s *Provider) EncryptContext(ctx context.Context, keyName string, plaintext []byte) (string, error) {
+	if !cryptoKeyName.MatchString(keyName) {
+		return "", fmt.Errorf("invalid key: expected projects/{PROJECT}/locations/{LOCATION}/keyRings/{KEYRING}/cryptoKeys/{KEY}")
+	}
+	req := &kmspb.EncryptRequest{
+		Name:      keyName,
+		Plaintext: plaintext,
+	}
+	resp, err := s.KMSClient.Encrypt(ctx, req, s.callOptions()...)
+	if err != nil {
+		return "", apiError(err, "failed to encrypt with Google Cloud KMS")
+	}
+	return kmsScheme + "://" + keyName + "?ciphertext=" + base64.StdEncoding.EncodeToString(resp.Ciphertext), nil
+}
+
+// decodeBase64 accepts both standard and URL-safe base64, with or without padding.
+func decodeBase64(s string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err == nil {
+		return data, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, rawErr := enc.DecodeString(s); rawErr == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+// GoogleSecretsManagerAPI represents KeyManagementClient interface for stub
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . GoogleSecretsManagerAPI
+
+type GoogleSecretsManagerAPI interface {
+	// ref. https://pkg.go.dev/cloud.google.com/go/secretmanager/apiv1?tab=doc#example-Client.AccessSecretVersion
+	AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error) // go:nolint
+	GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest, opts ...gax.CallOption) (*secretmanagerpb.Secret, error)
+	// ref. https://pkg.go.dev/cloud.google.com/go/secretmanager/apiv1?tab=doc#example-Client.GetSecretVersion
+	GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error)
+}
+
+// GoogleKeyManagementAPI represents KeyManagementClient interface for stub
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . GoogleKeyManagementAPI
+
+type GoogleKeyManagementAPI interface {
+	// ref. https://pkg.go.dev/cloud.google.com/go/kms/apiv1?tab=doc#example-KeyManagementClient.Decrypt
+	Decrypt(ctx context.Context, req *kmspb.DecryptRequest, opts ...gax.CallOption) (*kmspb.DecryptResponse, error)
+	// ref. https://pkg.go.dev/cloud.google.com/go/kms/apiv1?tab=doc#example-KeyManagementClient.Encrypt
+	Encrypt(ctx context.Context, req *kmspb.EncryptRequest, opts ...gax.CallOption) (*kmspb.EncryptResponse, error)
+}
@@ -6,7 +6,7 @@ import (
 	"sync"
 
 	gax "github.com/googleapis/gax-go/v2"
-	"github.com/telia-oss/gcp-env/internal/secrets"
+	"github.com/telia-oss/gcp-env/pkg/secrets"
 	kms "google.golang.org/genproto/googleapis/cloud/kms/v1"
 )
 
@@ -26,6 +26,21 @@ type FakeGoogleKeyManagementAPI struct {
 		result1 *kms.DecryptResponse
 		result2 error
 	}
+	EncryptStub        func(context.Context, *kms.EncryptRequest, ...gax.CallOption) (*kms.EncryptResponse, error)
+	encryptMutex       sync.RWMutex
+	encryptArgsForCall []struct {
+		arg1 context.Context
+		arg2 *kms.EncryptRequest
+		arg3 []gax.CallOption
+	}
+	encryptReturns struct {
+		result1 *kms.EncryptResponse
+		result2 error
+	}
+	encryptReturnsOnCall map[int]struct {
+		result1 *kms.EncryptResponse
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -96,11 +111,75 @@ func (fake *FakeGoogleKeyManagementAPI) DecryptReturnsOnCall(i int, result1 *kms
 	}{result1, result2}
 }
 
+func (fake *FakeGoogleKeyManagementAPI) Encrypt(arg1 context.Context, arg2 *kms.EncryptRequest, arg3 ...gax.CallOption) (*kms.EncryptResponse, error) {
+	fake.encryptMutex.Lock()
+	ret, specificReturn := fake.encryptReturnsOnCall[len(fake.encryptArgsForCall)]
+	fake.encryptArgsForCall = append(fake.encryptArgsForCall, struct {
+		arg1 context.Context
+		arg2 *kms.EncryptRequest
+		arg3 []gax.CallOption
+	}{arg1, arg2, arg3})
+	stub := fake.EncryptStub
+	fakeReturns := fake.encryptReturns
+	fake.recordInvocation("Encrypt", []interface{}{arg1, arg2, arg3})
+	fake.encryptMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeGoogleKeyManagementAPI) EncryptCallCount() int {
+	fake.encryptMutex.RLock()
+	defer fake.encryptMutex.RUnlock()
+	return len(fake.encryptArgsForCall)
+}
+
+func (fake *FakeGoogleKeyManagementAPI) EncryptCalls(stub func(context.Context, *kms.EncryptRequest, ...gax.CallOption) (*kms.EncryptResponse, error)) {
+	fake.encryptMutex.Lock()
+	defer fake.encryptMutex.Unlock()
+	fake.EncryptStub = stub
+}
+
+func (fake *FakeGoogleKeyManagementAPI) EncryptArgsForCall(i int) (context.Context, *kms.EncryptRequest, []gax.CallOption) {
+	fake.encryptMutex.RLock()
+	defer fake.encryptMutex.RUnlock()
+	argsForCall := fake.encryptArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeGoogleKeyManagementAPI) EncryptReturns(result1 *kms.EncryptResponse, result2 error) {
+	fake.encryptMutex.Lock()
+	defer fake.encryptMutex.Unlock()
+	fake.EncryptStub = nil
+	fake.encryptReturns = struct {
+		result1 *kms.EncryptResponse
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGoogleKeyManagementAPI) EncryptReturnsOnCall(i int, result1 *kms.EncryptResponse, result2 error) {
+	fake.encryptMutex.Lock()
+	defer fake.encryptMutex.Unlock()
+	fake.EncryptStub = nil
+	if fake.encryptReturnsOnCall == nil {
+		fake.encryptReturnsOnCall = make(map[int]struct {
+			result1 *kms.EncryptResponse
+			result2 error
+		})
+	}
+	fake.encryptReturnsOnCall[i] = struct {
+		result1 *kms.EncryptResponse
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeGoogleKeyManagementAPI) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.decryptMutex.RLock()
-	defer fake.decryptMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
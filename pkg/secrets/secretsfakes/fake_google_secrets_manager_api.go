@@ -6,7 +6,7 @@ import (
 	"sync"
 
 	gax "github.com/googleapis/gax-go/v2"
-	"github.com/telia-oss/gcp-env/internal/secrets"
+	"github.com/telia-oss/gcp-env/pkg/secrets"
 	secretmanager "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
 )
 
@@ -41,6 +41,21 @@ type FakeGoogleSecretsManagerAPI struct {
 		result1 *secretmanager.Secret
 		result2 error
 	}
+	GetSecretVersionStub        func(context.Context, *secretmanager.GetSecretVersionRequest, ...gax.CallOption) (*secretmanager.SecretVersion, error)
+	getSecretVersionMutex       sync.RWMutex
+	getSecretVersionArgsForCall []struct {
+		arg1 context.Context
+		arg2 *secretmanager.GetSecretVersionRequest
+		arg3 []gax.CallOption
+	}
+	getSecretVersionReturns struct {
+		result1 *secretmanager.SecretVersion
+		result2 error
+	}
+	getSecretVersionReturnsOnCall map[int]struct {
+		result1 *secretmanager.SecretVersion
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -177,13 +192,75 @@ func (fake *FakeGoogleSecretsManagerAPI) GetSecretReturnsOnCall(i int, result1 *
 	}{result1, result2}
 }
 
+func (fake *FakeGoogleSecretsManagerAPI) GetSecretVersion(arg1 context.Context, arg2 *secretmanager.GetSecretVersionRequest, arg3 ...gax.CallOption) (*secretmanager.SecretVersion, error) {
+	fake.getSecretVersionMutex.Lock()
+	ret, specificReturn := fake.getSecretVersionReturnsOnCall[len(fake.getSecretVersionArgsForCall)]
+	fake.getSecretVersionArgsForCall = append(fake.getSecretVersionArgsForCall, struct {
+		arg1 context.Context
+		arg2 *secretmanager.GetSecretVersionRequest
+		arg3 []gax.CallOption
+	}{arg1, arg2, arg3})
+	stub := fake.GetSecretVersionStub
+	fakeReturns := fake.getSecretVersionReturns
+	fake.recordInvocation("GetSecretVersion", []interface{}{arg1, arg2, arg3})
+	fake.getSecretVersionMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeGoogleSecretsManagerAPI) GetSecretVersionCallCount() int {
+	fake.getSecretVersionMutex.RLock()
+	defer fake.getSecretVersionMutex.RUnlock()
+	return len(fake.getSecretVersionArgsForCall)
+}
+
+func (fake *FakeGoogleSecretsManagerAPI) GetSecretVersionCalls(stub func(context.Context, *secretmanager.GetSecretVersionRequest, ...gax.CallOption) (*secretmanager.SecretVersion, error)) {
+	fake.getSecretVersionMutex.Lock()
+	defer fake.getSecretVersionMutex.Unlock()
+	fake.GetSecretVersionStub = stub
+}
+
+func (fake *FakeGoogleSecretsManagerAPI) GetSecretVersionArgsForCall(i int) (context.Context, *secretmanager.GetSecretVersionRequest, []gax.CallOption) {
+	fake.getSecretVersionMutex.RLock()
+	defer fake.getSecretVersionMutex.RUnlock()
+	argsForCall := fake.getSecretVersionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeGoogleSecretsManagerAPI) GetSecretVersionReturns(result1 *secretmanager.SecretVersion, result2 error) {
+	fake.getSecretVersionMutex.Lock()
+	defer fake.getSecretVersionMutex.Unlock()
+	fake.GetSecretVersionStub = nil
+	fake.getSecretVersionReturns = struct {
+		result1 *secretmanager.SecretVersion
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGoogleSecretsManagerAPI) GetSecretVersionReturnsOnCall(i int, result1 *secretmanager.SecretVersion, result2 error) {
+	fake.getSecretVersionMutex.Lock()
+	defer fake.getSecretVersionMutex.Unlock()
+	fake.GetSecretVersionStub = nil
+	if fake.getSecretVersionReturnsOnCall == nil {
+		fake.getSecretVersionReturnsOnCall = make(map[int]struct {
+			result1 *secretmanager.SecretVersion
+			result2 error
+		})
+	}
+	fake.getSecretVersionReturnsOnCall[i] = struct {
+		result1 *secretmanager.SecretVersion
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeGoogleSecretsManagerAPI) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.accessSecretVersionMutex.RLock()
-	defer fake.accessSecretVersionMutex.RUnlock()
-	fake.getSecretMutex.RLock()
-	defer fake.getSecretMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Template is a value with references embedded as ${REFERENCE}, e.g.
+//
+//	postgres://app:${sm://projects/p/secrets/db-pass}@db:5432/app
+//
+// A literal "${" is written as "$${". Other occurrences of ${...} which do
+// not hold a reference (e.g. ${HOME}) are kept as is.
+type Template struct {
+	parts []templatePart
+}
+
+type templatePart struct {
+	text string
+	ref  bool
+}
+
+// ParseTemplate parses the embedded references of value.
+func ParseTemplate(value string) (*Template, error) {
+	t := &Template{}
+	var text strings.Builder
+	for i := 0; i < len(value); {
+		switch {
+		case strings.HasPrefix(value[i:], "$${"):
+			text.WriteString("${")
+			i += 3
+		case strings.HasPrefix(value[i:], "${"):
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				if _, ok := schemeOf(value[i+2:]); ok {
+					return nil, fmt.Errorf("unterminated reference at offset %d", i)
+				}
+				text.WriteString(value[i:])
+				i = len(value)
+				continue
+			}
+			inner := value[i+2 : i+2+end]
+			if _, ok := schemeOf(inner); !ok {
+				text.WriteString(value[i : i+3+end])
+				i += 3 + end
+				continue
+			}
+			if text.Len() > 0 {
+				t.parts = append(t.parts, templatePart{text: text.String()})
+				text.Reset()
+			}
+			t.parts = append(t.parts, templatePart{text: inner, ref: true})
+			i += 3 + end
+		default:
+			text.WriteByte(value[i])
+			i++
+		}
+	}
+	if text.Len() > 0 {
+		t.parts = append(t.parts, templatePart{text: text.String()})
+	}
+	return t, nil
+}
+
+// References returns the references embedded in the template, in order.
+func (t *Template) References() []string {
+	var refs []string
+	for _, part := range t.parts {
+		if part.ref {
+			refs = append(refs, part.text)
+		}
+	}
+	return refs
+}
+
+// Execute replaces each reference with its value in secrets.
+func (t *Template) Execute(secrets map[string]string) (string, error) {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if !part.ref {
+			b.WriteString(part.text)
+			continue
+		}
+		secret, ok := secrets[part.text]
+		if !ok {
+			return "", fmt.Errorf("missing value for reference: '%s'", Redact(part.text))
+		}
+		b.WriteString(secret)
+	}
+	return b.String(), nil
+}
+
+// Interpolate replaces the references embedded in value with their secrets.
+// References whose scheme has no resolver are kept as is.
+func (s *Provider) Interpolate(value string) (string, error) {
+	return s.InterpolateContext(context.Background(), value)
+}
+
+// InterpolateContext is like Interpolate, using ctx for the API calls.
+func (s *Provider) InterpolateContext(ctx context.Context, value string) (string, error) {
+	t, err := ParseTemplate(value)
+	if err != nil {
+		return "", err
+	}
+	var refs []string
+	secrets := make(map[string]string)
+	for _, ref := range t.References() {
+		if s.Supports(ref) {
+			refs = append(refs, ref)
+		} else {
+			secrets[ref] = "${" + ref + "}"
+		}
+	}
+	values, err := s.ResolveSecretsContext(ctx, refs)
+	if err != nil {
+		return "", err
+	}
+	for i, ref := range refs {
+		secrets[ref] = values[i]
+	}
+	return t.Execute(secrets)
+}